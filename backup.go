@@ -0,0 +1,112 @@
+package ccdb
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// BackupOptions configure backups
+type BackupOptions struct {
+	// Index additionally writes an index for the backup to the given path.
+	Index string
+}
+
+// Backup creates a consistent copy of a log file, which may be in use by
+// a writer. The copy contains all entries up to the position recorded in
+// the header of the source, i.e. all committed data.
+//
+// On Linux, data is transferred via copy_file_range(), which creates
+// reflinks on supporting file systems. Hard links are never used, as
+// headers are rewritten in place when logs are appended to.
+func Backup(logFile, dst string, opt *BackupOptions) error {
+	src, err := os.Open(logFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	header, err := readFileHeader(src)
+	if err != nil {
+		return err
+	}
+	return backupLog(src, header, dst, opt)
+}
+
+// Snapshot creates a consistent point-in-time copy of the log, containing
+// all entries written before the call. Pending data is committed first and
+// writes are blocked until the copy is complete. See Backup for details.
+func (w *LogWriter) Snapshot(dst string, opt *BackupOptions) error {
+	src, err := os.Open(w.file.Name())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// commit releases the lock while syncing, capture the position first
+	// to exclude entries appended by others in the meantime
+	pos := w.header.pos
+	if err := w.commit(pos); err != nil {
+		return err
+	}
+
+	header := *w.header
+	header.pos = pos
+	return backupLog(src, &header, dst, opt)
+}
+
+// backupLog copies the header and all data up to header.pos from src into
+// a temporary file, which is then renamed to dst.
+func backupLog(src *os.File, header *fileHeader, dst string, opt *BackupOptions) error {
+	if opt == nil {
+		opt = new(BackupOptions)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if info, err := src.Stat(); err != nil {
+		return err
+	} else if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if _, err := header.WriteTo(tmp); err != nil {
+		return err
+	}
+	if _, err := src.Seek(header.dataOffset(), io.SeekStart); err != nil {
+		return err
+	}
+
+	// copying from an io.LimitedReader of an *os.File allows
+	// the runtime to use copy_file_range()
+	n := header.pos - header.dataOffset()
+	if m, err := io.Copy(tmp, io.LimitReader(src, n)); err != nil {
+		return err
+	} else if m != n {
+		return io.ErrUnexpectedEOF
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+
+	if opt.Index != "" {
+		return WriteIndex(opt.Index, dst)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package ccdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backup", func() {
+	var dir, fname string
+	var writer *LogWriter
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = filepath.Join(dir, "test.ccl")
+
+		var err error
+		writer, err = CreateLogWithOptions(fname, &LogOptions{FileID: 0x1234})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Put([]byte("foo"), []byte("v1"))).To(Succeed())
+		Expect(writer.Put([]byte("bar"), []byte("v2"))).To(Succeed())
+	})
+
+	AfterEach(func() {
+		writer.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should snapshot live logs", func() {
+		dst, index := filepath.Join(dir, "snap.ccl"), filepath.Join(dir, "snap.cci")
+		Expect(writer.Snapshot(dst, &BackupOptions{Index: index})).To(Succeed())
+		Expect(writer.Put([]byte("foo"), []byte("v3"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+
+		info, err := os.Stat(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(Equal(int64(142)))
+		Expect(readValues(index, dst, "foo")).To(Equal([]string{"v1"}))
+		Expect(readValues(index, dst, "bar")).To(Equal([]string{"v2"}))
+
+		reader, err := OpenLog(dst)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		Expect(reader.Info().ID).To(Equal(uint32(0x1234)))
+	})
+
+	It("should back up committed data only", func() {
+		Expect(writer.Flush()).To(Succeed())
+		Expect(writer.Put([]byte("foo"), []byte("v3"))).To(Succeed())
+		Expect(writer.buffer.Flush()).To(Succeed())
+
+		dst := filepath.Join(dir, "backup.ccl")
+		Expect(Backup(fname, dst, nil)).To(Succeed())
+
+		data, err := ioutil.ReadFile(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(HaveLen(142))
+
+		src, err := ioutil.ReadFile(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(src).To(HaveLen(149))
+		Expect(data[fileHeaderLen:]).To(Equal(src[fileHeaderLen:142]))
+
+		index := filepath.Join(dir, "backup.cci")
+		Expect(WriteIndex(index, dst)).To(Succeed())
+		Expect(readValues(index, dst, "foo")).To(Equal([]string{"v1"}))
+	})
+
+	It("should snapshot while others write", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				Expect(writer.Put([]byte("baz"), []byte("v3"))).To(Succeed())
+				Expect(writer.Flush()).To(Succeed())
+			}
+		}()
+
+		dst := filepath.Join(dir, "backup.ccl")
+		for i := 0; i < 20; i++ {
+			Expect(writer.Snapshot(dst, nil)).To(Succeed())
+
+			reader, err := OpenLog(dst)
+			Expect(err).NotTo(HaveOccurred())
+			info, err := os.Stat(dst)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reader.Info().Size).To(Equal(info.Size()))
+			Expect(reader.Close()).To(Succeed())
+		}
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("should replace existing files", func() {
+		dst := filepath.Join(dir, "backup.ccl")
+		Expect(ioutil.WriteFile(dst, []byte("garbage"), 0644)).To(Succeed())
+		Expect(writer.Snapshot(dst, nil)).To(Succeed())
+
+		entries, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+
+		reader, err := OpenLog(dst)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		Expect(reader.Info().Size).To(Equal(int64(142)))
+	})
+
+})
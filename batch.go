@@ -0,0 +1,99 @@
+package ccdb
+
+import "time"
+
+// Batch collects key/value pairs which are appended to a log atomically.
+// Batches are not thread-safe.
+type Batch struct {
+	w *LogWriter
+
+	data    []byte // key/value data
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	klen, vlen int
+	ttl        time.Duration
+}
+
+// NewBatch creates a new, empty batch
+func (w *LogWriter) NewBatch() *Batch {
+	return &Batch{w: w}
+}
+
+// Put adds a key/value pair to the batch. Data is copied
+// and buffered in memory until the batch is committed.
+func (b *Batch) Put(key, val []byte) error {
+	return b.PutWithTTL(key, val, 0)
+}
+
+// PutWithTTL adds a key/value pair which expires after ttl, see LogWriter.PutWithTTL
+func (b *Batch) PutWithTTL(key, val []byte, ttl time.Duration) error {
+	if err := b.w.validate(key, int64(len(val))); err != nil {
+		return err
+	}
+	if err := b.w.validateTTL(ttl); err != nil {
+		return err
+	}
+
+	b.data = append(b.data, key...)
+	b.data = append(b.data, val...)
+	b.entries = append(b.entries, batchEntry{klen: len(key), vlen: len(val), ttl: ttl})
+	return nil
+}
+
+// Len returns the number of buffered entries
+func (b *Batch) Len() int { return len(b.entries) }
+
+// Reset discards all buffered entries
+func (b *Batch) Reset() {
+	b.data = b.data[:0]
+	b.entries = b.entries[:0]
+}
+
+// Commit appends all buffered entries to the log and commits them. Entries
+// only become visible once they are synced, so readers never see a batch
+// whose commit failed. On failure, the uncommitted tail of the log is
+// discarded or, if other callers appended in the meantime, the writer fails.
+// The batch is reset after a successful commit.
+func (b *Batch) Commit() error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+
+	w := b.w
+	t := time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// flush pending data, so a rollback only affects the batch
+	if err := w.buffer.Flush(); err != nil {
+		return err
+	}
+
+	start := w.header.pos
+	now := w.clock()
+	data := b.data
+	for _, ent := range b.entries {
+		key, val := data[:ent.klen], data[ent.klen:ent.klen+ent.vlen]
+		data = data[ent.klen+ent.vlen:]
+
+		if err := w.writeEntry(key, val, now, ent.ttl); err != nil {
+			return w.discard(start, w.header.pos, err)
+		}
+	}
+	w.unsynced += len(b.entries)
+	end := w.header.pos
+
+	if err := w.commit(end); err == errDiscarded {
+		return err
+	} else if err != nil {
+		return w.discard(start, end, err)
+	}
+	if w.obs != nil {
+		w.obs.ObservePut(len(b.entries), end-start, time.Since(t))
+	}
+
+	b.Reset()
+	return nil
+}
@@ -0,0 +1,135 @@
+package ccdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batch", func() {
+	var writer *LogWriter
+	var subject *Batch
+	var dir, fname string
+
+	var readAll = func() []Entry {
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		var acc []Entry
+		iter := reader.Iterator()
+		for iter.Next() {
+			acc = append(acc, *iter.Entry())
+		}
+		Expect(iter.Error()).NotTo(HaveOccurred())
+		return acc
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = filepath.Join(dir, "data.ccl")
+
+		var err error
+		writer, err = CreateLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		subject = writer.NewBatch()
+	})
+
+	AfterEach(func() {
+		writer.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should disallow blanks", func() {
+		Expect(subject.Put([]byte{}, []byte("value"))).To(Equal(errBlankKey))
+		Expect(subject.Put([]byte("key"), []byte{})).To(Equal(errBlankValue))
+		Expect(subject.Len()).To(Equal(0))
+	})
+
+	It("should only expose entries on commit", func() {
+		Expect(writer.Put([]byte("key0"), []byte("value0"))).To(Succeed())
+		Expect(subject.Put([]byte("key1"), []byte("value1"))).To(Succeed())
+		Expect(subject.Put([]byte("key2"), []byte("value2"))).To(Succeed())
+		Expect(subject.Len()).To(Equal(2))
+
+		Expect(writer.Flush()).To(Succeed())
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+		}))
+
+		Expect(subject.Commit()).To(Succeed())
+		Expect(subject.Len()).To(Equal(0))
+		Expect(writer.synced).To(Equal(int64(164)))
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+			{Pos: 140, Key: []byte("key1"), Val: []byte("value1")},
+			{Pos: 152, Key: []byte("key2"), Val: []byte("value2")},
+		}))
+	})
+
+	It("should roll back uncommitted tails", func() {
+		Expect(writer.Put([]byte("key0"), []byte("value0"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+
+		done := writer.PutAsync([]byte("key1"), []byte("value1"))
+		Expect(writer.buffer.Flush()).To(Succeed())
+		Expect(writer.rollback(140)).To(Succeed())
+		Expect(done).To(Receive(Equal(errDiscarded)))
+		Expect(writer.header.pos).To(Equal(int64(140)))
+
+		stat, err := os.Stat(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stat.Size()).To(Equal(int64(140)))
+
+		Expect(subject.Put([]byte("key2"), []byte("value2"))).To(Succeed())
+		Expect(subject.Commit()).To(Succeed())
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+			{Pos: 140, Key: []byte("key2"), Val: []byte("value2")},
+		}))
+	})
+
+	It("should discard failed writes", func() {
+		Expect(writer.Put([]byte("key0"), []byte("value0"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+		Expect(writer.Put([]byte("key1"), []byte("value1"))).To(Succeed())
+
+		errFake := errors.New("fake")
+		Expect(writer.discard(140, 152, errFake)).To(Equal(errFake))
+		Expect(writer.header.pos).To(Equal(int64(140)))
+		Expect(writer.failed).NotTo(HaveOccurred())
+		Expect(writer.Put([]byte("key2"), []byte("value2"))).To(Succeed())
+	})
+
+	It("should fail instead of discarding writes of other callers", func() {
+		Expect(writer.Put([]byte("key0"), []byte("value0"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+		Expect(writer.Put([]byte("key1"), []byte("value1"))).To(Succeed())
+		Expect(writer.Put([]byte("key2"), []byte("value2"))).To(Succeed())
+
+		errFake := errors.New("fake")
+		Expect(writer.discard(140, 152, errFake)).To(Equal(errFake))
+		Expect(writer.header.pos).To(Equal(int64(164)))
+		Expect(writer.Put([]byte("key3"), []byte("value3"))).To(Equal(errWriterFailed))
+		Expect(subject.Put([]byte("key3"), []byte("value3"))).To(Succeed())
+		Expect(subject.Commit()).To(Equal(errWriterFailed))
+	})
+
+	It("should not expose failed commits", func() {
+		Expect(writer.Put([]byte("key0"), []byte("value0"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+		Expect(writer.Put([]byte("key1"), []byte("value1"))).To(Succeed())
+		Expect(writer.buffer.Flush()).To(Succeed())
+		Expect(writer.file.Close()).To(Succeed())
+
+		Expect(subject.Put([]byte("key2"), []byte("value2"))).To(Succeed())
+		Expect(subject.Commit()).To(HaveOccurred())
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+		}))
+	})
+
+})
@@ -4,15 +4,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 )
 
 const (
 	magicNumber  uint16 = 0xCCDB
 	majorVersion uint16 = 1
-	minorVersion uint16 = 0
+	minorVersion uint16 = 2 // latest supported minor version
 
 	numBuckets = 256
 
+	flagTimestamps uint16 = 1 << 0 // entries carry timestamps and TTLs
+	flagEncrypted  uint16 = 1 << 1 // values are encrypted
+	flagExtended   uint16 = 1 << 2 // metadata is stored in an extended header section
+	knownFlags            = flagTimestamps | flagEncrypted | flagExtended
+
 	checksumInit csum32 = 5381 // Initial checksum value
 )
 
@@ -24,8 +30,41 @@ var (
 	errHeaderDifferent         = errors.New("ccdb: file headers differ")
 	errBadFileID               = errors.New("ccdb: bad file ID")
 	errInvalidOffset           = errors.New("ccdb: invalid offset")
+	errInvalidEntry            = errors.New("ccdb: invalid entry")
 	errBlankKey                = errors.New("ccdb: keys must not be blank")
 	errBlankValue              = errors.New("ccdb: values must not be blank")
+	errDiscarded               = errors.New("ccdb: uncommitted data was discarded")
+	errValueSize               = errors.New("ccdb: value size mismatch")
+	errClosed                  = errors.New("ccdb: writer is closed")
+	errWriterFailed            = errors.New("ccdb: writer failed, uncommitted data could not be discarded")
+	errShardMismatch           = errors.New("ccdb: shard mismatch")
+	errMergeDestination        = errors.New("ccdb: merge destination must not be a source")
+	errDecrypt                 = errors.New("ccdb: value decryption failed")
+	errNotSealed               = errors.New("ccdb: index does not cover the log")
+	errReplicaAhead            = errors.New("ccdb: replica is ahead of the leader")
+	errMissingColumn           = errors.New("ccdb: missing column")
+	errMissingField            = errors.New("ccdb: missing field")
+	errCodecSize               = errors.New("ccdb: invalid encoded size")
+)
+
+// Validation errors
+var (
+	ErrInvalidBufferSize = errors.New("ccdb: invalid buffer size")
+	ErrInvalidSizeLimit  = errors.New("ccdb: invalid size limit")
+	ErrFileIDMismatch    = errors.New("ccdb: file ID mismatch")
+	ErrKeyTooLarge       = errors.New("ccdb: key too large")
+	ErrValueTooLarge     = errors.New("ccdb: value too large")
+	ErrInvalidShardCount = errors.New("ccdb: invalid shard count")
+	ErrMetadataTooLarge  = errors.New("ccdb: metadata too large")
+	ErrMetadataImmutable = errors.New("ccdb: metadata can only be set on new logs")
+	ErrInvalidTTL        = errors.New("ccdb: invalid TTL")
+	ErrNoTimestamps      = errors.New("ccdb: log does not store timestamps")
+	ErrMissingKey        = errors.New("ccdb: encryption key required")
+	ErrNotEncrypted      = errors.New("ccdb: log is not encrypted")
+	ErrInvalidSignature  = errors.New("ccdb: invalid signature")
+	ErrUnknownFormat     = errors.New("ccdb: unknown format")
+	ErrUnknownEncoding   = errors.New("ccdb: unknown encoding")
+	ErrInvalidUTF8       = errors.New("ccdb: invalid UTF-8, use hex or base64 encoding")
 )
 
 type version struct {
@@ -39,13 +78,23 @@ type slot struct {
 
 // --------------------------------------------------------------------
 
-type logEntry struct {
+// Entry is a key/value pair, stored at a log position.
+// Timestamp and TTL are only set for logs with timestamps.
+type Entry struct {
 	Pos      int64
 	Key, Val []byte
+
+	Timestamp time.Time
+	TTL       time.Duration
 }
 
-func (e *logEntry) Checksum() csum32 { return checksum(e.Key) }
-func (e *logEntry) String() string   { return fmt.Sprintf("%010d: %s %s", e.Pos, e.Key, e.Val) }
+func (e *Entry) String() string   { return fmt.Sprintf("%010d: %s %s", e.Pos, e.Key, e.Val) }
+func (e *Entry) checksum() csum32 { return checksum(e.Key) }
+
+// Expired returns true if the entry has a TTL which has expired as of now
+func (e *Entry) Expired(now time.Time) bool {
+	return e.TTL > 0 && !e.Timestamp.IsZero() && !now.Before(e.Timestamp.Add(e.TTL))
+}
 
 // --------------------------------------------------------------------
 
@@ -87,6 +87,32 @@ func writeTestWithCollisions(dir string, size int) (string, string, error) {
 	return lname, iname, writer.WriteIndex(iname)
 }
 
+func readValues(iname, lname, key string) []string {
+	db, err := Open(iname, lname)
+	Expect(err).NotTo(HaveOccurred())
+	defer db.Close()
+
+	return getValues(db, key)
+}
+
+type valueGetter interface {
+	Get(key []byte) (*Iterator, error)
+}
+
+func getValues(db valueGetter, key string) []string {
+	iter, err := db.Get([]byte(key))
+	Expect(err).NotTo(HaveOccurred())
+
+	vals, err := iter.All()
+	Expect(err).NotTo(HaveOccurred())
+
+	strs := make([]string, 0, len(vals))
+	for _, val := range vals {
+		strs = append(strs, string(val))
+	}
+	return strs
+}
+
 // --------------------------------------------------------------------
 
 func mkTemp() string {
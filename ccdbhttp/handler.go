@@ -0,0 +1,238 @@
+// Package ccdbhttp serves read requests for a ccdb.DB over HTTP.
+//
+// Endpoints:
+//
+//	GET  /keys/{key}  returns all values of a key
+//	HEAD /keys/{key}  checks if a key exists
+//	POST /keys        looks up multiple keys, i.e. {"keys":["a","b"]}
+//	GET  /health      reports the header info of the DB
+//
+// Keys and values are encoded according to the encoding query
+// parameter: raw (default), hex or base64. Raw JSON responses are rejected
+// with 406 if keys or values are not valid UTF-8. Values are returned as
+// JSON, unless the request accepts application/octet-stream, which streams
+// the first value, or multipart/mixed, which streams all values.
+package ccdbhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bsm/ccdb"
+)
+
+const maxBatchSize = 4 * 1024 * 1024
+
+var errInvalidUTF8 = errors.New("ccdbhttp: invalid UTF-8, use hex or base64 encoding")
+
+// Result contains the values of a key
+type Result struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// Health is returned by the health endpoint
+type Health struct {
+	Status string        `json:"status"`
+	Info   ccdb.FileInfo `json:"info"`
+}
+
+type handler struct {
+	db *ccdb.DB
+}
+
+// NewHandler returns a read-only http.Handler for db
+func NewHandler(db *ccdb.DB) http.Handler {
+	return &handler{db: db}
+}
+
+// ServeHTTP implements http.Handler
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/health" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &Health{Status: "ok", Info: h.db.Info()})
+	case r.URL.Path == "/keys" && r.Method == http.MethodPost:
+		h.batch(w, r)
+	case strings.HasPrefix(r.URL.Path, "/keys/") && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		h.get(w, r)
+	case r.URL.Path == "/health" || r.URL.Path == "/keys" || strings.HasPrefix(r.URL.Path, "/keys/"):
+		writeError(w, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	enc := ccdb.Encoding(r.URL.Query().Get("encoding"))
+	key, err := enc.Decode(strings.TrimPrefix(r.URL.Path, "/keys/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	iter, err := h.db.Get(key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case r.Method == http.MethodHead:
+		if !iter.Next() {
+			h.notFound(w, iter)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case strings.Contains(accept, "application/octet-stream"):
+		if !iter.Next() {
+			h.notFound(w, iter)
+			return
+		}
+		section := iter.Section()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(section.Size(), 10))
+		io.Copy(w, section)
+	case strings.Contains(accept, "multipart/mixed"):
+		h.streamMultipart(w, iter)
+	default:
+		res, err := collect(enc, key, iter)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+		} else if len(res.Values) == 0 {
+			writeJSON(w, http.StatusNotFound, res)
+		} else {
+			writeJSON(w, http.StatusOK, res)
+		}
+	}
+}
+
+func (h *handler) streamMultipart(w http.ResponseWriter, iter *ccdb.Iterator) {
+	if !iter.Next() {
+		h.notFound(w, iter)
+		return
+	}
+
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusOK)
+
+	for ok := true; ok; ok = iter.Next() {
+		section := iter.Section()
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":   {"application/octet-stream"},
+			"Content-Length": {strconv.FormatInt(section.Size(), 10)},
+		})
+		if err != nil {
+			return
+		}
+		if _, err := io.Copy(part, section); err != nil {
+			return
+		}
+	}
+	if iter.Error() == nil {
+		mw.Close()
+	}
+}
+
+func (h *handler) batch(w http.ResponseWriter, r *http.Request) {
+	enc := ccdb.Encoding(r.URL.Query().Get("encoding"))
+	if _, err := enc.Decode(""); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBatchSize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results := make([]*Result, 0, len(req.Keys))
+	for _, s := range req.Keys {
+		key, err := enc.Decode(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		iter, err := h.db.Get(key)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		res, err := collect(enc, key, iter)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		results = append(results, res)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// notFound responds with 404, unless iteration failed
+func (h *handler) notFound(w http.ResponseWriter, iter *ccdb.Iterator) {
+	if err := iter.Error(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeError(w, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+}
+
+// --------------------------------------------------------------------
+
+func collect(enc ccdb.Encoding, key []byte, iter *ccdb.Iterator) (*Result, error) {
+	vals, err := iter.All()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{Values: make([]string, 0, len(vals))}
+	if res.Key, err = encode(enc, key); err != nil {
+		return nil, err
+	}
+	for _, val := range vals {
+		s, err := encode(enc, val)
+		if err != nil {
+			return nil, err
+		}
+		res.Values = append(res.Values, s)
+	}
+	return res, nil
+}
+
+// encode encodes b for JSON responses, raw data must be valid UTF-8
+func encode(enc ccdb.Encoding, b []byte) (string, error) {
+	if (enc == "" || enc == ccdb.EncodingRaw) && !utf8.Valid(b) {
+		return "", errInvalidUTF8
+	}
+	return enc.Encode(b), nil
+}
+
+func errorStatus(err error) int {
+	if err == errInvalidUTF8 {
+		return http.StatusNotAcceptable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,161 @@
+package ccdbhttp
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bsm/ccdb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var dir string
+	var db *ccdb.DB
+	var subject http.Handler
+
+	var serve = func(method, target, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		subject.ServeHTTP(rec, req)
+		return rec
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ccdbhttp-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		lname, iname := filepath.Join(dir, "db.ccl"), filepath.Join(dir, "db.cci")
+		writer, err := ccdb.CreateLogWithOptions(lname, &ccdb.LogOptions{FileID: 0x1234})
+		Expect(err).NotTo(HaveOccurred())
+		for _, kv := range [][]string{{"foo", "v1"}, {"bar", "v2"}, {"foo", "v3"}, {"a/b", "v4"}, {"bin", "\xff\xfe"}} {
+			Expect(writer.Put([]byte(kv[0]), []byte(kv[1]))).To(Succeed())
+		}
+		Expect(writer.WriteIndex(iname)).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		db, err = ccdb.Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		subject = NewHandler(db)
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should get values", func() {
+		rec := serve("GET", "/keys/foo", "", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(rec.Body.String()).To(Equal(`{"key":"foo","values":["v1","v3"]}` + "\n"))
+
+		rec = serve("GET", "/keys/a%2Fb", "", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal(`{"key":"a/b","values":["v4"]}` + "\n"))
+
+		rec = serve("GET", "/keys/666f6f?encoding=hex", "", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal(`{"key":"666f6f","values":["7631","7633"]}` + "\n"))
+
+		rec = serve("GET", "/keys/missing", "", "")
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+		Expect(rec.Body.String()).To(Equal(`{"key":"missing","values":[]}` + "\n"))
+
+		rec = serve("GET", "/keys/foo?encoding=rot13", "", "")
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject binary values in raw encoding", func() {
+		rec := serve("GET", "/keys/bin", "", "")
+		Expect(rec.Code).To(Equal(http.StatusNotAcceptable))
+		Expect(rec.Body.String()).To(ContainSubstring("invalid UTF-8"))
+
+		rec = serve("GET", "/keys/62696e?encoding=base64", "", "")
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+
+		rec = serve("GET", "/keys/Ymlu?encoding=base64", "", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal(`{"key":"Ymlu","values":["//4="]}` + "\n"))
+
+		rec = serve("POST", "/keys", "", `{"keys":["foo","bin"]}`)
+		Expect(rec.Code).To(Equal(http.StatusNotAcceptable))
+	})
+
+	It("should stream raw values", func() {
+		rec := serve("GET", "/keys/foo", "application/octet-stream", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Length")).To(Equal("2"))
+		Expect(rec.Body.String()).To(Equal("v1"))
+
+		rec = serve("GET", "/keys/missing", "application/octet-stream", "")
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should stream multipart values", func() {
+		rec := serve("GET", "/keys/foo", "multipart/mixed", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mediaType).To(Equal("multipart/mixed"))
+
+		var vals []string
+		mr := multipart.NewReader(rec.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			data, err := ioutil.ReadAll(part)
+			Expect(err).NotTo(HaveOccurred())
+			vals = append(vals, string(data))
+		}
+		Expect(vals).To(Equal([]string{"v1", "v3"}))
+	})
+
+	It("should check existence", func() {
+		Expect(serve("HEAD", "/keys/bar", "", "").Code).To(Equal(http.StatusOK))
+		Expect(serve("HEAD", "/keys/missing", "", "").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should look up batches", func() {
+		rec := serve("POST", "/keys", "", `{"keys":["foo","missing","bar"]}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal(`{"results":[{"key":"foo","values":["v1","v3"]},{"key":"missing","values":[]},{"key":"bar","values":["v2"]}]}` + "\n"))
+
+		rec = serve("POST", "/keys", "", `{"keys":`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should report health", func() {
+		rec := serve("GET", "/health", "", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal(`{"status":"ok","info":{"major_version":1,"minor_version":0,"id":4660,"size":163}}` + "\n"))
+	})
+
+	It("should reject bad requests", func() {
+		Expect(serve("GET", "/", "", "").Code).To(Equal(http.StatusNotFound))
+		Expect(serve("DELETE", "/keys/foo", "", "").Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(serve("GET", "/keys", "", "").Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+})
+
+// --------------------------------------------------------------------
+
+func TestSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ccdb/ccdbhttp")
+}
@@ -0,0 +1,471 @@
+// Package ccdbresp serves read-only access to a ccdb.DB over the Redis
+// serialization protocol (RESP), for use with redis-cli and other clients.
+//
+// Supported commands:
+//
+//	GET key                 returns the first (or last) value of a key
+//	MGET key [key ...]      returns the first (or last) values of multiple keys
+//	LRANGE key start stop   returns a range of values of a key
+//	EXISTS key [key ...]    returns the number of existing keys
+//	INFO [section]          returns header info, index statistics with section stats or all
+//	PING [message], ECHO message, COMMAND, QUIT
+package ccdbresp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bsm/ccdb"
+)
+
+const (
+	defaultMaxArgs    = 1024
+	defaultMaxBulkLen = 1024 * 1024
+)
+
+var (
+	errProtocol = errors.New("ccdbresp: protocol error")
+	errClosed   = errors.New("ccdbresp: server closed")
+)
+
+// Options can be used to configure servers
+type Options struct {
+	// Last makes GET and MGET return the last, rather than the first value of a key.
+	Last bool
+
+	// MaxArgs limits the number of arguments per command. Default: 1024
+	MaxArgs int
+
+	// MaxBulkLen limits the size of each argument in bytes. Default: 1MiB
+	MaxBulkLen int
+}
+
+func (o *Options) norm() Options {
+	var opt Options
+	if o != nil {
+		opt = *o
+	}
+	if opt.MaxArgs <= 0 {
+		opt.MaxArgs = defaultMaxArgs
+	}
+	if opt.MaxBulkLen <= 0 {
+		opt.MaxBulkLen = defaultMaxBulkLen
+	}
+	return opt
+}
+
+// Server serves a DB over RESP
+type Server struct {
+	db  *ccdb.DB
+	opt Options
+
+	mutex     sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer creates a new server for db
+func NewServer(db *ccdb.DB, opt *Options) *Server {
+	return &Server{
+		db:        db,
+		opt:       opt.norm(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on a TCP address and serves connections
+func (s *Server) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Serve accepts and serves connections from lis until the server is closed
+func (s *Server) Serve(lis net.Listener) error {
+	if !s.track(lis, nil) {
+		lis.Close()
+		return errClosed
+	}
+	defer s.untrack(lis, nil)
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return errClosed
+			}
+			return err
+		}
+		if !s.track(nil, conn) {
+			conn.Close()
+			return errClosed
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(nil, conn)
+
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops all listeners, closes all connections and waits for them to finish
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for lis := range s.listeners {
+		lis.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.closed
+}
+
+func (s *Server) track(lis net.Listener, conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if lis != nil {
+		s.listeners[lis] = struct{}{}
+	}
+	if conn != nil {
+		s.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (s *Server) untrack(lis net.Listener, conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if lis != nil {
+		delete(s.listeners, lis)
+	}
+	if conn != nil {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+// serveConn reads and handles commands until the connection is closed
+func (s *Server) serveConn(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := &writer{Writer: bufio.NewWriter(conn)}
+
+	for {
+		args, err := readCommand(r, s.opt.MaxArgs, s.opt.MaxBulkLen)
+		if err == errProtocol {
+			w.Error("ERR Protocol error")
+			w.Flush()
+			return
+		} else if err != nil {
+			return
+		}
+
+		quit := s.handle(w, args)
+
+		// flush once all pipelined commands are handled
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// handle handles a command, returns true if the connection should be closed
+func (s *Server) handle(w *writer, args [][]byte) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	name := strings.ToUpper(string(args[0]))
+	args = args[1:]
+
+	switch {
+	case name == "GET" && len(args) == 1:
+		s.get(w, args[0])
+	case name == "MGET" && len(args) != 0:
+		w.ArrayHeader(len(args))
+		for _, key := range args {
+			s.get(w, key)
+		}
+	case name == "LRANGE" && len(args) == 3:
+		s.lrange(w, args[0], args[1], args[2])
+	case name == "EXISTS" && len(args) != 0:
+		s.exists(w, args)
+	case name == "INFO" && len(args) <= 1:
+		s.info(w, args)
+	case name == "PING" && len(args) == 0:
+		w.Status("PONG")
+	case name == "PING" && len(args) == 1, name == "ECHO" && len(args) == 1:
+		w.Bulk(args[0])
+	case name == "COMMAND":
+		w.ArrayHeader(0)
+	case name == "QUIT":
+		w.Status("OK")
+		return true
+	case name == "GET", name == "MGET", name == "LRANGE", name == "EXISTS",
+		name == "INFO", name == "PING", name == "ECHO":
+		w.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+	default:
+		w.Error(fmt.Sprintf("ERR unknown command '%.64s'", strings.ToLower(name)))
+	}
+	return false
+}
+
+func (s *Server) get(w *writer, key []byte) {
+	iter, err := s.db.Get(key)
+	if err != nil {
+		w.Error("ERR " + err.Error())
+		return
+	}
+
+	var val []byte
+	for iter.Next() {
+		if val, err = iter.Value(); err != nil {
+			w.Error("ERR " + err.Error())
+			return
+		}
+		if !s.opt.Last {
+			break
+		}
+	}
+	if err := iter.Error(); err != nil {
+		w.Error("ERR " + err.Error())
+		return
+	}
+	w.Bulk(val)
+}
+
+func (s *Server) lrange(w *writer, key, start, stop []byte) {
+	first, err1 := strconv.Atoi(string(start))
+	last, err2 := strconv.Atoi(string(stop))
+	if err1 != nil || err2 != nil {
+		w.Error("ERR value is not an integer or out of range")
+		return
+	}
+
+	iter, err := s.db.Get(key)
+	if err != nil {
+		w.Error("ERR " + err.Error())
+		return
+	}
+	vals, err := iter.All()
+	if err != nil {
+		w.Error("ERR " + err.Error())
+		return
+	}
+
+	// apply redis semantics to negative and out-of-range indexes
+	n := len(vals)
+	if first < 0 {
+		first += n
+	}
+	if last < 0 {
+		last += n
+	}
+	if first < 0 {
+		first = 0
+	}
+	if last >= n {
+		last = n - 1
+	}
+	if first > last {
+		w.ArrayHeader(0)
+		return
+	}
+
+	w.ArrayHeader(last - first + 1)
+	for _, val := range vals[first : last+1] {
+		w.Bulk(val)
+	}
+}
+
+func (s *Server) exists(w *writer, keys [][]byte) {
+	n := 0
+	for _, key := range keys {
+		iter, err := s.db.Get(key)
+		if err != nil {
+			w.Error("ERR " + err.Error())
+			return
+		}
+		if iter.Next() {
+			n++
+		} else if err := iter.Error(); err != nil {
+			w.Error("ERR " + err.Error())
+			return
+		}
+	}
+	w.Integer(int64(n))
+}
+
+func (s *Server) info(w *writer, args [][]byte) {
+	section := "default"
+	if len(args) != 0 {
+		section = strings.ToLower(string(args[0]))
+	}
+
+	var buf strings.Builder
+	if section == "all" || section == "default" || section == "server" {
+		info := s.db.Info()
+		fmt.Fprintf(&buf, "# Server\r\nccdb_version:%d.%d\r\nfile_id:%08x\r\nsize:%d\r\ntimestamps:%d\r\nencrypted:%d\r\n",
+			info.MajorVersion, info.MinorVersion, info.ID, info.Size, boolInt(info.Timestamps), boolInt(info.Encrypted))
+		keys := make([]string, 0, len(info.Metadata))
+		for key := range info.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&buf, "metadata_%s:%s\r\n", key, info.Metadata[key])
+		}
+	}
+	// stats scan the entire index, so they are only reported on request
+	if section == "all" || section == "stats" {
+		stats, err := s.db.Stats()
+		if err != nil {
+			w.Error("ERR " + err.Error())
+			return
+		}
+		if buf.Len() != 0 {
+			buf.WriteString("\r\n")
+		}
+		fmt.Fprintf(&buf, "# Stats\r\nentries:%d\r\ndistinct_checksums:%d\r\nshared_checksums:%d\r\ncollisions:%d\r\nslots:%d\r\nfill:%.3f\r\nmax_probe:%d\r\navg_probe:%.3f\r\n",
+			stats.Entries, stats.DistinctChecksums, stats.SharedChecksums, stats.Collisions, stats.Slots, stats.Fill, stats.MaxProbe, stats.AvgProbe)
+	}
+	w.Bulk([]byte(buf.String()))
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// --------------------------------------------------------------------
+
+// readCommand reads a command, either as an array of bulk strings
+// or as an inline command. Memory is allocated as data arrives, rather
+// than as announced by the client.
+func readCommand(r *bufio.Reader, maxArgs, maxBulkLen int) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		var args [][]byte
+		for _, field := range strings.Fields(string(line)) {
+			args = append(args, []byte(field))
+		}
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 || n > maxArgs {
+		return nil, errProtocol
+	}
+
+	var args [][]byte
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		} else if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errProtocol
+		}
+
+		var buf bytes.Buffer
+		if m, err := io.CopyN(&buf, r, int64(size)+2); err == io.EOF && m != 0 {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		arg := buf.Bytes()
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, errProtocol
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// readLine reads a CRLF or LF terminated line
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errProtocol
+	} else if err != nil {
+		return nil, err
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n != 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// --------------------------------------------------------------------
+
+type writer struct {
+	*bufio.Writer
+}
+
+func (w *writer) Status(s string) { w.WriteString("+" + s + "\r\n") }
+func (w *writer) Error(s string)  { w.WriteString("-" + s + "\r\n") }
+
+func (w *writer) Integer(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w *writer) ArrayHeader(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// Bulk writes a bulk string, nil values are written as null
+func (w *writer) Bulk(b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,170 @@
+package ccdbresp
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bsm/ccdb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var dir string
+	var db *ccdb.DB
+	var subject *Server
+	var conn net.Conn
+	var served chan error
+
+	// roundTrip sends a raw request and reads n reply lines
+	var roundTrip = func(req string, n int) string {
+		_, err := io.WriteString(conn, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		r := bufio.NewReader(conn)
+		var acc []string
+		for i := 0; i < n; i++ {
+			line, err := r.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			acc = append(acc, line)
+		}
+		Expect(r.Buffered()).To(Equal(0))
+		return strings.Join(acc, "")
+	}
+
+	var command = func(args ...string) string {
+		req := "*" + strconv.Itoa(len(args)) + "\r\n"
+		for _, arg := range args {
+			req += "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+		}
+		return req
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ccdbresp-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		lname, iname := filepath.Join(dir, "db.ccl"), filepath.Join(dir, "db.cci")
+		writer, err := ccdb.CreateLogWithOptions(lname, &ccdb.LogOptions{FileID: 0x1234})
+		Expect(err).NotTo(HaveOccurred())
+		for _, kv := range [][]string{{"foo", "v1"}, {"bar", "v2"}, {"foo", "v3"}, {"foo", "v4"}} {
+			Expect(writer.Put([]byte(kv[0]), []byte(kv[1]))).To(Succeed())
+		}
+		Expect(writer.WriteIndex(iname)).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		db, err = ccdb.Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		subject = NewServer(db, nil)
+		served = make(chan error, 1)
+		go func() { served <- subject.Serve(lis) }()
+
+		conn, err = net.Dial("tcp", lis.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		Expect(subject.Close()).To(Succeed())
+		Expect(<-served).To(Equal(errClosed))
+		db.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should get values", func() {
+		Expect(roundTrip(command("GET", "foo"), 2)).To(Equal("$2\r\nv1\r\n"))
+		Expect(roundTrip(command("get", "missing"), 1)).To(Equal("$-1\r\n"))
+		Expect(roundTrip(command("MGET", "foo", "missing", "bar"), 6)).To(Equal("*3\r\n$2\r\nv1\r\n$-1\r\n$2\r\nv2\r\n"))
+	})
+
+	It("should get last values", func() {
+		subject.opt.Last = true
+		Expect(roundTrip(command("GET", "foo"), 2)).To(Equal("$2\r\nv4\r\n"))
+	})
+
+	It("should get ranges", func() {
+		Expect(roundTrip(command("LRANGE", "foo", "0", "-1"), 7)).To(Equal("*3\r\n$2\r\nv1\r\n$2\r\nv3\r\n$2\r\nv4\r\n"))
+		Expect(roundTrip(command("LRANGE", "foo", "-2", "10"), 5)).To(Equal("*2\r\n$2\r\nv3\r\n$2\r\nv4\r\n"))
+		Expect(roundTrip(command("LRANGE", "foo", "2", "1"), 1)).To(Equal("*0\r\n"))
+		Expect(roundTrip(command("LRANGE", "missing", "0", "-1"), 1)).To(Equal("*0\r\n"))
+		Expect(roundTrip(command("LRANGE", "foo", "x", "1"), 1)).To(Equal("-ERR value is not an integer or out of range\r\n"))
+	})
+
+	It("should check existence", func() {
+		Expect(roundTrip(command("EXISTS", "foo", "missing", "bar", "foo"), 1)).To(Equal(":3\r\n"))
+	})
+
+	It("should report info", func() {
+		res := roundTrip(command("INFO", "server"), 8)
+		Expect(res).To(HavePrefix("$"))
+		Expect(res).To(ContainSubstring("# Server\r\nccdb_version:1.0\r\nfile_id:00001234\r\nsize:156\r\n"))
+
+		res = roundTrip(command("INFO"), 8)
+		Expect(res).To(ContainSubstring("# Server\r\n"))
+		Expect(res).NotTo(ContainSubstring("# Stats\r\n"))
+
+		res = roundTrip(command("INFO", "stats"), 11)
+		Expect(res).To(ContainSubstring("# Stats\r\nentries:4\r\ndistinct_checksums:2\r\nshared_checksums:1\r\ncollisions:0\r\n"))
+
+		res = roundTrip(command("INFO", "all"), 18)
+		Expect(res).To(ContainSubstring("# Server\r\n"))
+		Expect(res).To(ContainSubstring("# Stats\r\n"))
+	})
+
+	It("should support pipelining and inline commands", func() {
+		Expect(roundTrip(command("PING")+command("ECHO", "hi")+"GET bar\r\n", 5)).To(Equal("+PONG\r\n$2\r\nhi\r\n$2\r\nv2\r\n"))
+	})
+
+	It("should handle errors", func() {
+		Expect(roundTrip(command("SET", "foo", "bar"), 1)).To(Equal("-ERR unknown command 'set'\r\n"))
+		Expect(roundTrip(command("GET"), 1)).To(Equal("-ERR wrong number of arguments for 'get' command\r\n"))
+		Expect(roundTrip("*1\r\n#3\r\n", 1)).To(Equal("-ERR Protocol error\r\n"))
+
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("should enforce limits", func() {
+		read := func(req string) ([][]byte, error) {
+			return readCommand(bufio.NewReader(strings.NewReader(req)), 2, 3)
+		}
+		Expect(read(command("GET", "foo"))).To(Equal([][]byte{[]byte("GET"), []byte("foo")}))
+		_, err := read(command("MGET", "foo", "bar"))
+		Expect(err).To(Equal(errProtocol))
+		_, err = read(command("GET", "fooo"))
+		Expect(err).To(Equal(errProtocol))
+		_, err = read("*1\r\n$3\r\nfo")
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+	})
+
+	It("should limit bulk lengths", func() {
+		Expect(roundTrip("*1\r\n$1048577\r\n", 1)).To(Equal("-ERR Protocol error\r\n"))
+	})
+
+	It("should quit", func() {
+		Expect(roundTrip(command("QUIT"), 1)).To(Equal("+OK\r\n"))
+
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(Equal(io.EOF))
+	})
+
+})
+
+// --------------------------------------------------------------------
+
+func TestSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ccdb/ccdbresp")
+}
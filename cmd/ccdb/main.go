@@ -0,0 +1,513 @@
+// Command ccdb inspects and manipulates ccdb databases.
+//
+// Usage:
+//
+//	ccdb [flags] info FILE...
+//	ccdb [flags] get INDEX LOG KEY
+//	ccdb [flags] put LOG KEY VALUE
+//	ccdb [flags] dump LOG
+//	ccdb [flags] index LOG INDEX
+//	ccdb [flags] verify INDEX LOG
+//	ccdb [flags] stats INDEX LOG
+//	ccdb [flags] recover [-salvage] [-dry-run] LOG
+//	ccdb [flags] serve [-addr ADDR] INDEX LOG
+//	ccdb [flags] import [-type TYPE] [-key KEY] [-value VALUE] [-header] [-skip-invalid] [-index INDEX] LOG [FILE]
+//	ccdb [flags] export [-type TYPE] [-prefix PREFIX] [-start POS] [-end POS] [-offsets] [-header] LOG [FILE]
+//
+// Flags:
+//
+//	-format    output format: text or json (default: text)
+//	-encoding  key/value encoding: raw, hex or base64 (default: raw)
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/bsm/ccdb"
+	"github.com/bsm/ccdb/ccdbhttp"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+var errUsage = errors.New("invalid usage")
+
+// listenAndServe serves HTTP requests, replaced in tests
+var listenAndServe = http.ListenAndServe
+
+type command func(c *cli, args []string) error
+
+var commands = map[string]command{
+	"info":    (*cli).info,
+	"get":     (*cli).get,
+	"put":     (*cli).put,
+	"dump":    (*cli).dump,
+	"index":   (*cli).index,
+	"verify":  (*cli).verify,
+	"stats":   (*cli).stats,
+	"recover": (*cli).recover,
+	"serve":   (*cli).serve,
+	"import":  (*cli).importData,
+	"export":  (*cli).exportData,
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout}
+
+	flags := flag.NewFlagSet("ccdb", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&c.format, "format", "text", "output format: text or json")
+	flags.StringVar(&c.encoding, "encoding", "raw", "key/value encoding: raw, hex or base64")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ccdb [flags] info|get|put|dump|index|verify|stats|recover|serve|import|export ARGS...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		return 2
+	}
+	if c.format != "text" && c.format != "json" {
+		fmt.Fprintf(stderr, "ccdb: unknown format %q\n", c.format)
+		return 2
+	}
+	if c.encoding != "raw" && c.encoding != "hex" && c.encoding != "base64" {
+		fmt.Fprintf(stderr, "ccdb: unknown encoding %q\n", c.encoding)
+		return 2
+	}
+
+	if err := cmd(c, flags.Args()[1:]); err == errUsage {
+		flags.Usage()
+		return 2
+	} else if err != nil {
+		fmt.Fprintf(stderr, "ccdb: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// --------------------------------------------------------------------
+
+type cli struct {
+	stdout   io.Writer
+	format   string
+	encoding string
+}
+
+func (c *cli) decode(s string) ([]byte, error) {
+	return ccdb.Encoding(c.encoding).Decode(s)
+}
+
+func (c *cli) encode(b []byte) string {
+	return ccdb.Encoding(c.encoding).Encode(b)
+}
+
+// encodeJSON encodes b for JSON output, rejecting binary data in raw encoding.
+func (c *cli) encodeJSON(b []byte) (string, error) {
+	return ccdb.Encoding(c.encoding).EncodeJSON(b)
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	return json.NewEncoder(c.stdout).Encode(v)
+}
+
+func (c *cli) info(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	for _, fname := range args {
+		reader, err := ccdb.OpenLog(fname)
+		if err != nil {
+			return err
+		}
+		info := reader.Info()
+		reader.Close()
+
+		if c.format == "json" {
+			if err := c.printJSON(struct {
+				File string `json:"file"`
+				ccdb.FileInfo
+			}{File: fname, FileInfo: info}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(c.stdout, "File: %s\n%s", fname, info)
+	}
+	return nil
+}
+
+func (c *cli) get(args []string) error {
+	if len(args) != 3 {
+		return errUsage
+	}
+
+	key, err := c.decode(args[2])
+	if err != nil {
+		return err
+	}
+
+	db, err := ccdb.Open(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	iter, err := db.Get(key)
+	if err != nil {
+		return err
+	}
+
+	vals, err := iter.All()
+	if err != nil {
+		return err
+	}
+
+	if c.format == "json" {
+		skey, err := c.encodeJSON(key)
+		if err != nil {
+			return err
+		}
+		strs := make([]string, 0, len(vals))
+		for _, val := range vals {
+			s, err := c.encodeJSON(val)
+			if err != nil {
+				return err
+			}
+			strs = append(strs, s)
+		}
+		return c.printJSON(struct {
+			Key    string   `json:"key"`
+			Values []string `json:"values"`
+		}{Key: skey, Values: strs})
+	}
+	for _, val := range vals {
+		fmt.Fprintln(c.stdout, c.encode(val))
+	}
+	return nil
+}
+
+func (c *cli) put(args []string) error {
+	if len(args) != 3 {
+		return errUsage
+	}
+
+	key, err := c.decode(args[1])
+	if err != nil {
+		return err
+	}
+	val, err := c.decode(args[2])
+	if err != nil {
+		return err
+	}
+
+	writer, err := ccdb.AppendLog(args[0])
+	if os.IsNotExist(err) {
+		writer, err = ccdb.CreateLog(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Put(key, val); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+func (c *cli) dump(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	reader, err := ccdb.OpenLog(args[0])
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	iter := reader.Iterator()
+	for iter.Next() {
+		ent := iter.Entry()
+		if c.format == "json" {
+			key, err := c.encodeJSON(ent.Key)
+			if err != nil {
+				return err
+			}
+			val, err := c.encodeJSON(ent.Val)
+			if err != nil {
+				return err
+			}
+			if err := c.printJSON(struct {
+				Pos   int64  `json:"pos"`
+				Key   string `json:"key"`
+				Value string `json:"value"`
+			}{Pos: ent.Pos, Key: key, Value: val}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(c.stdout, "%010d: %s %s\n", ent.Pos, c.encode(ent.Key), c.encode(ent.Val))
+	}
+	return iter.Error()
+}
+
+func (c *cli) index(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	return ccdb.WriteIndex(args[1], args[0])
+}
+
+func (c *cli) verify(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	report, err := ccdb.Verify(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	if c.format == "json" {
+		if err := c.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(c.stdout, "Entries: %d\nSlots: %d\n", report.Entries, report.Slots)
+		for _, p := range report.Problems {
+			fmt.Fprintln(c.stdout, "Problem:", p)
+		}
+	}
+
+	if !report.OK() {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+func (c *cli) stats(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	db, err := ccdb.Open(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log, err := ccdb.OpenLog(args[1])
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	istats, err := db.Stats()
+	if err != nil {
+		return err
+	}
+	lstats, err := log.Stats()
+	if err != nil {
+		return err
+	}
+
+	if c.format == "json" {
+		return c.printJSON(struct {
+			Index *ccdb.IndexStats `json:"index"`
+			Log   *ccdb.LogStats   `json:"log"`
+		}{Index: istats, Log: lstats})
+	}
+
+	fmt.Fprintf(c.stdout, "Index entries: %d\nDistinct checksums: %d\nShared checksums: %d\nCollisions: %d\n", istats.Entries, istats.DistinctChecksums, istats.SharedChecksums, istats.Collisions)
+	fmt.Fprintf(c.stdout, "Slots: %d\nFill: %.3f\nProbes: max %d, avg %.3f\nBytes covered: %d\n", istats.Slots, istats.Fill, istats.MaxProbe, istats.AvgProbe, istats.BytesCovered)
+	fmt.Fprintf(c.stdout, "Log entries: %d\nLog bytes: %d\n", lstats.Entries, lstats.Bytes)
+	printHistogram(c.stdout, "Key sizes", &lstats.KeySizes)
+	printHistogram(c.stdout, "Value sizes", &lstats.ValueSizes)
+	return nil
+}
+
+func printHistogram(w io.Writer, name string, h *ccdb.Histogram) {
+	fmt.Fprintf(w, "%s: min %d, max %d, avg %.1f\n", name, h.Min, h.Max, h.Mean())
+	for n, count := range h.Buckets {
+		if count == 0 {
+			continue
+		}
+		lo, hi := int64(0), int64(1)<<uint(n)
+		if n > 0 {
+			lo = hi >> 1
+		}
+		fmt.Fprintf(w, "  %d..%d: %d\n", lo, hi-1, count)
+	}
+}
+
+func (c *cli) recover(args []string) error {
+	opt := new(ccdb.RecoverOptions)
+	flags := flag.NewFlagSet("recover", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.BoolVar(&opt.Salvage, "salvage", false, "salvage entries beyond the header position")
+	flags.BoolVar(&opt.DryRun, "dry-run", false, "report only, do not modify the log")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	report, err := ccdb.RecoverLog(flags.Arg(0), opt)
+	if err != nil {
+		return err
+	}
+
+	if c.format == "json" {
+		return c.printJSON(struct {
+			*ccdb.RecoverReport
+			Repaired bool `json:"repaired"`
+		}{RecoverReport: report, Repaired: report.Repaired()})
+	}
+	_, err = fmt.Fprint(c.stdout, report)
+	return err
+}
+
+func (c *cli) serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	addr := flags.String("addr", ":8080", "listen address")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	db, err := ccdb.Open(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return listenAndServe(*addr, ccdbhttp.NewHandler(db))
+}
+
+func (c *cli) importData(args []string) error {
+	opt := &ccdb.ImportOptions{
+		KeyEncoding:   ccdb.Encoding(c.encoding),
+		ValueEncoding: ccdb.Encoding(c.encoding),
+	}
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("type", "csv", "input type: csv, tsv or jsonl")
+	key := flags.String("key", "", "key column (csv, tsv) or field (jsonl)")
+	val := flags.String("value", "", "value column (csv, tsv) or field (jsonl)")
+	flags.BoolVar(&opt.SkipHeader, "header", false, "skip the first row (csv, tsv)")
+	flags.BoolVar(&opt.SkipInvalid, "skip-invalid", false, "skip invalid rows")
+	flags.StringVar(&opt.Index, "index", "", "write an index after the import")
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 || flags.NArg() > 2 {
+		return errUsage
+	}
+
+	opt.Format = ccdb.Format(*format)
+	if opt.Format == ccdb.FormatJSONL {
+		opt.KeyField, opt.ValueField = *key, *val
+	} else {
+		var err error
+		if *key != "" {
+			if opt.KeyColumn, err = strconv.Atoi(*key); err != nil {
+				return errUsage
+			}
+		}
+		if *val != "" {
+			if opt.ValueColumn, err = strconv.Atoi(*val); err != nil {
+				return errUsage
+			}
+		}
+	}
+
+	var input io.Reader = os.Stdin
+	if flags.NArg() == 2 {
+		file, err := os.Open(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	writer, err := ccdb.AppendLog(flags.Arg(0))
+	if os.IsNotExist(err) {
+		writer, err = ccdb.CreateLog(flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := ccdb.Import(writer, input, opt)
+	if e := writer.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.format == "json" {
+		return c.printJSON(report)
+	}
+	_, err = fmt.Fprint(c.stdout, report)
+	return err
+}
+
+func (c *cli) exportData(args []string) error {
+	opt := &ccdb.ExportOptions{
+		KeyEncoding:   ccdb.Encoding(c.encoding),
+		ValueEncoding: ccdb.Encoding(c.encoding),
+	}
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("type", "jsonl", "output type: jsonl, csv, tsv or raw")
+	prefix := flags.String("prefix", "", "only export keys with the given prefix")
+	flags.Int64Var(&opt.Start, "start", 0, "offset of the first entry")
+	flags.Int64Var(&opt.End, "end", 0, "export entries starting before this offset")
+	flags.BoolVar(&opt.Offsets, "offsets", false, "include entry offsets")
+	flags.BoolVar(&opt.Header, "header", false, "write a header row (csv, tsv)")
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 || flags.NArg() > 2 {
+		return errUsage
+	}
+
+	opt.Format = ccdb.Format(*format)
+	if *prefix != "" {
+		var err error
+		if opt.Prefix, err = c.decode(*prefix); err != nil {
+			return err
+		}
+	}
+
+	reader, err := ccdb.OpenLog(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if flags.NArg() == 1 {
+		_, err := ccdb.Export(reader, c.stdout, opt)
+		return err
+	}
+
+	file, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if _, err := ccdb.Export(reader, file, opt); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
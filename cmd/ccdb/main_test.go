@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("run", func() {
+	var dir, lname, iname string
+
+	var ccdb = func(args ...string) (int, string, string) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+		code := run(args, stdout, stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ccdb-cmd-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		lname, iname = filepath.Join(dir, "db.ccl"), filepath.Join(dir, "db.cci")
+		for _, kv := range [][]string{{"foo", "v1"}, {"bar", "v2"}, {"foo", "v3"}} {
+			code, _, stderr := ccdb("put", lname, kv[0], kv[1])
+			Expect(stderr).To(BeEmpty())
+			Expect(code).To(Equal(0))
+		}
+
+		code, _, _ := ccdb("index", lname, iname)
+		Expect(code).To(Equal(0))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should print usage", func() {
+		code, _, stderr := ccdb()
+		Expect(code).To(Equal(2))
+		Expect(stderr).To(HavePrefix("Usage: ccdb"))
+
+		code, _, _ = ccdb("get", iname)
+		Expect(code).To(Equal(2))
+
+		code, _, stderr = ccdb("-format", "xml", "dump", lname)
+		Expect(code).To(Equal(2))
+		Expect(stderr).To(Equal("ccdb: unknown format \"xml\"\n"))
+	})
+
+	It("should print info", func() {
+		code, stdout, _ := ccdb("info", lname, iname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(MatchRegexp(`^File: .+db\.ccl\nVersion 1\.0\nIdentifier: [0-9a-f]{8}\nSize: 149\nFile: .+db\.cci\nVersion 1\.0\nIdentifier: [0-9a-f]{8}\nSize: 149\n$`))
+
+		code, stdout, _ = ccdb("-format", "json", "info", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(MatchRegexp(`^\{"file":".+db\.ccl","major_version":1,"minor_version":0,"id":\d+,"size":149\}\n$`))
+	})
+
+	It("should get", func() {
+		code, stdout, _ := ccdb("get", iname, lname, "foo")
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("v1\nv3\n"))
+
+		code, stdout, _ = ccdb("-format", "json", "-encoding", "hex", "get", iname, lname, "666f6f")
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(`{"key":"666f6f","values":["7631","7633"]}` + "\n"))
+
+		code, _, stderr := ccdb("-encoding", "hex", "get", iname, lname, "foo")
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(HavePrefix("ccdb: encoding/hex: invalid byte"))
+	})
+
+	It("should put", func() {
+		code, _, _ := ccdb("-encoding", "base64", "put", lname, "YmF6", "djQ=")
+		Expect(code).To(Equal(0))
+
+		code, stdout, _ := ccdb("dump", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(HaveSuffix("0000000149: baz v4\n"))
+	})
+
+	It("should dump", func() {
+		code, stdout, _ := ccdb("dump", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("0000000128: foo v1\n0000000135: bar v2\n0000000142: foo v3\n"))
+
+		code, stdout, _ = ccdb("-format", "json", "-encoding", "base64", "dump", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(HavePrefix(`{"pos":128,"key":"Zm9v","value":"djE="}` + "\n"))
+	})
+
+	It("should reject binary data in raw JSON", func() {
+		code, _, _ := ccdb("-encoding", "hex", "put", lname, "62696e", "fffe00")
+		Expect(code).To(Equal(0))
+		code, _, _ = ccdb("index", lname, iname)
+		Expect(code).To(Equal(0))
+
+		code, _, stderr := ccdb("-format", "json", "get", iname, lname, "bin")
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("invalid UTF-8"))
+
+		code, _, stderr = ccdb("-format", "json", "dump", lname)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(ContainSubstring("invalid UTF-8"))
+
+		code, stdout, _ := ccdb("-format", "json", "-encoding", "base64", "get", iname, lname, "Ymlu")
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(`{"key":"Ymlu","values":["//4A"]}` + "\n"))
+	})
+
+	It("should verify", func() {
+		code, stdout, _ := ccdb("verify", iname, lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("Entries: 3\nSlots: 3\n"))
+
+		code, _, _ = ccdb("put", lname, "baz", "v4")
+		Expect(code).To(Equal(0))
+
+		code, stdout, stderr := ccdb("-format", "json", "verify", iname, lname)
+		Expect(code).To(Equal(1))
+		Expect(stdout).To(ContainSubstring(`"entries":3,"slots":3,"header_mismatch":false,"stale":true,`))
+		Expect(stdout).To(HaveSuffix(`"problems":["index covers 149 of 156 bytes"]}` + "\n"))
+		Expect(stderr).To(Equal("ccdb: verification failed\n"))
+	})
+
+	It("should print stats", func() {
+		code, stdout, _ := ccdb("stats", iname, lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(HavePrefix("Index entries: 3\nDistinct checksums: 2\nShared checksums: 1\nCollisions: 0\nSlots: 6\n"))
+		Expect(stdout).To(HaveSuffix("Key sizes: min 3, max 3, avg 3.0\n  2..3: 3\nValue sizes: min 2, max 2, avg 2.0\n  2..3: 3\n"))
+
+		code, stdout, _ = ccdb("-format", "json", "stats", iname, lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(HavePrefix(`{"index":{"entries":3,"distinct_checksums":2,"shared_checksums":1,"collisions":0,"slots":6,"fill":0.5,`))
+	})
+
+	It("should serve", func() {
+		defer func(orig func(string, http.Handler) error) { listenAndServe = orig }(listenAndServe)
+
+		var addr string
+		var rec *httptest.ResponseRecorder
+		listenAndServe = func(a string, h http.Handler) error {
+			addr, rec = a, httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/keys/foo", nil))
+			return nil
+		}
+
+		code, _, _ := ccdb("serve", "-addr", "127.0.0.1:9999", iname, lname)
+		Expect(code).To(Equal(0))
+		Expect(addr).To(Equal("127.0.0.1:9999"))
+		Expect(rec.Body.String()).To(Equal(`{"key":"foo","values":["v1","v3"]}` + "\n"))
+
+		code, _, _ = ccdb("serve", iname)
+		Expect(code).To(Equal(2))
+	})
+
+	It("should import", func() {
+		input := filepath.Join(dir, "input.jsonl")
+		Expect(ioutil.WriteFile(input, []byte(`{"id":"baz","data":{"v":"v4"}}`+"\n"+`{"id":"qux"}`+"\n"), 0644)).To(Succeed())
+
+		code, stdout, _ := ccdb("import", "-type", "jsonl", "-key", "id", "-value", "/data/v", "-skip-invalid", "-index", iname, lname, input)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("Rows: 2\nImported: 1\nSkipped: 1\nError: row 2: ccdb: missing field\n"))
+
+		code, stdout, _ = ccdb("get", iname, lname, "baz")
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("v4\n"))
+
+		input = filepath.Join(dir, "input.csv")
+		Expect(ioutil.WriteFile(input, []byte("v5,qux\n"), 0644)).To(Succeed())
+
+		code, stdout, _ = ccdb("-format", "json", "import", "-key", "2", "-value", "1", lname, input)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(`{"rows":1,"imported":1,"skipped":0}` + "\n"))
+
+		code, stdout, _ = ccdb("dump", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(HaveSuffix("baz v4\n0000000156: qux v5\n"))
+
+		code, _, stderr := ccdb("import", "-type", "xml", lname, input)
+		Expect(code).To(Equal(1))
+		Expect(stderr).To(Equal("ccdb: ccdb: unknown format\n"))
+
+		code, _, _ = ccdb("import", "-key", "x", lname, input)
+		Expect(code).To(Equal(2))
+	})
+
+	It("should export", func() {
+		code, stdout, _ := ccdb("export", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(`{"key":"foo","value":"v1"}` + "\n" + `{"key":"bar","value":"v2"}` + "\n" + `{"key":"foo","value":"v3"}` + "\n"))
+
+		code, stdout, _ = ccdb("-encoding", "hex", "export", "-type", "csv", "-prefix", "66", "-offsets", "-header", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal("key,value,pos\n666f6f,7631,128\n666f6f,7633,142\n"))
+
+		output := filepath.Join(dir, "output.tsv")
+		code, _, _ = ccdb("export", "-type", "tsv", "-start", "135", lname, output)
+		Expect(code).To(Equal(0))
+		Expect(ioutil.ReadFile(output)).To(Equal([]byte("bar\tv2\nfoo\tv3\n")))
+
+		code, _, _ = ccdb("export")
+		Expect(code).To(Equal(2))
+	})
+
+	It("should recover", func() {
+		code, stdout, _ := ccdb("recover", "-dry-run", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(ContainSubstring("Truncated: 0\n"))
+
+		code, stdout, _ = ccdb("-format", "json", "recover", lname)
+		Expect(code).To(Equal(0))
+		Expect(stdout).To(Equal(`{"file_size":149,"header_pos":149,"pos":149,"entries":3,"salvaged":0,"truncated":0,"repaired":false}` + "\n"))
+	})
+
+})
+
+// --------------------------------------------------------------------
+
+func TestSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ccdb/cmd/ccdb")
+}
@@ -0,0 +1,82 @@
+package ccdb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// gcmOverhead is the size of the nonce prepended and the
+// authentication tag appended to encrypted values
+const gcmOverhead = 12 + 16
+
+// KeyProvider provides AES keys of 16, 24 or 32 bytes by ID. The ID is
+// stored in the header of each encrypted file, so keys can be rotated by
+// writing new files with a new ID while old keys remain available for reads.
+type KeyProvider interface {
+	Key(id uint32) ([]byte, error)
+}
+
+// StaticKeys is a simple, in-memory KeyProvider
+type StaticKeys map[uint32][]byte
+
+// Key implements KeyProvider
+func (k StaticKeys) Key(id uint32) ([]byte, error) {
+	if key, ok := k[id]; ok {
+		return key, nil
+	}
+	return nil, ErrMissingKey
+}
+
+// newAEAD returns an AES-GCM cipher for the key referenced by the header
+func newAEAD(keys KeyProvider, header *fileHeader) (cipher.AEAD, error) {
+	if keys == nil {
+		return nil, ErrMissingKey
+	}
+
+	key, err := keys.Key(header.keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// entryAAD returns the additional data authenticated with a value: the
+// file ID, the entry position and the key, binding values to their entries.
+func entryAAD(id uint32, pos int64, key []byte) []byte {
+	aad := make([]byte, 12, 12+len(key))
+	binary.LittleEndian.PutUint32(aad[0:], id)
+	binary.LittleEndian.PutUint64(aad[4:], uint64(pos))
+	return append(aad, key...)
+}
+
+// sealValue encrypts a value stored at pos under a random nonce, which is
+// prepended to the result. Nonces must never repeat under the same key, so
+// they are not derived from positions, which are reused after rollbacks
+// and recoveries.
+func sealValue(aead cipher.AEAD, id uint32, pos int64, key, val []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(val)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, val, entryAAD(id, pos, key)), nil
+}
+
+// openValue decrypts a value stored at pos in-place
+func openValue(aead cipher.AEAD, id uint32, pos int64, key, val []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	if len(val) < n+aead.Overhead() {
+		return nil, errDecrypt
+	}
+	plain, err := aead.Open(val[n:n], val[:n], val[n:], entryAAD(id, pos, key))
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plain, nil
+}
@@ -0,0 +1,169 @@
+package ccdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Encryption", func() {
+	var dir, fname string
+
+	keys := StaticKeys{
+		1: bytes.Repeat([]byte{1}, 16),
+		2: bytes.Repeat([]byte{2}, 32),
+	}
+
+	var writeLog = func(name string, keyID uint32, kvs ...string) string {
+		fname := filepath.Join(dir, name)
+		writer, err := CreateLogWithOptions(fname, &LogOptions{Keys: keys, KeyID: keyID})
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < len(kvs); i += 2 {
+			Expect(writer.Put([]byte(kvs[i]), []byte(kvs[i+1]))).To(Succeed())
+		}
+		Expect(writer.WriteIndex(strings.TrimSuffix(fname, ".ccl") + ".cci")).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+		return fname
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = writeLog("data.ccl", 1, "foo", "secret1", "bar", "secret2", "foo", "secret3")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should encrypt values", func() {
+		data, err := ioutil.ReadFile(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("foo"))
+		Expect(string(data)).NotTo(ContainSubstring("secret"))
+
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		Expect(reader.Info().Encrypted).To(BeTrue())
+		Expect(reader.Info().KeyID).To(Equal(uint32(1)))
+		Expect(reader.Info().MinorVersion).To(Equal(uint16(1)))
+	})
+
+	It("should decrypt transparently", func() {
+		db, err := OpenWithOptions(filepath.Join(dir, "data.cci"), fname, &ReadOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(getValues(db, "foo")).To(Equal([]string{"secret1", "secret3"}))
+
+		iter, err := db.Get([]byte("bar"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.Next()).To(BeTrue())
+		Expect(iter.Section().Size()).To(Equal(int64(7)))
+		Expect(readSection(iter.Section())).To(Equal([]byte("secret2")))
+
+		var acc []string
+		logIter := db.log.Iterator()
+		for logIter.Next() {
+			acc = append(acc, string(logIter.Entry().Val))
+		}
+		Expect(logIter.Error()).NotTo(HaveOccurred())
+		Expect(acc).To(Equal([]string{"secret1", "secret2", "secret3"}))
+	})
+
+	It("should require keys to read values", func() {
+		db, err := Open(filepath.Join(dir, "data.cci"), fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		iter, err := db.Get([]byte("foo"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.Next()).To(BeFalse())
+		Expect(iter.Error()).To(Equal(ErrMissingKey))
+
+		logIter := db.log.Iterator()
+		Expect(logIter.Next()).To(BeFalse())
+		Expect(logIter.Error()).To(Equal(ErrMissingKey))
+
+		_, err = OpenLogWithOptions(fname, &ReadOptions{Keys: StaticKeys{}})
+		Expect(err).To(Equal(ErrMissingKey))
+		_, err = AppendLog(fname)
+		Expect(err).To(Equal(ErrMissingKey))
+	})
+
+	It("should detect tampering", func() {
+		data, err := ioutil.ReadFile(fname)
+		Expect(err).NotTo(HaveOccurred())
+		data[fileHeaderLen+6]++
+		Expect(ioutil.WriteFile(fname, data, 0644)).To(Succeed())
+
+		reader, err := OpenLogWithOptions(fname, &ReadOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		_, _, err = reader.Get(fileHeaderLen)
+		Expect(err).To(Equal(errDecrypt))
+	})
+
+	It("should not reuse nonces after rollbacks", func() {
+		writer, err := AppendLogWithOptions(fname, &LogOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		defer writer.Close()
+
+		pos := writer.Size()
+		readNonce := func() []byte {
+			Expect(writer.Flush()).To(Succeed())
+			data, err := ioutil.ReadFile(fname)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(data)).To(BeNumerically(">", int(pos)+5+12))
+			return data[pos+5 : pos+5+12] // after lengths and key
+		}
+
+		Expect(writer.Put([]byte("baz"), []byte("secret4"))).To(Succeed())
+		nonce1 := readNonce()
+		Expect(writer.discard(pos, writer.Size(), errDecrypt)).To(Equal(errDecrypt))
+		Expect(writer.Size()).To(Equal(pos))
+
+		Expect(writer.Put([]byte("baz"), []byte("secret4"))).To(Succeed())
+		nonce2 := readNonce()
+		Expect(nonce2).NotTo(Equal(nonce1))
+	})
+
+	It("should stream values", func() {
+		writer, err := AppendLogWithOptions(fname, &LogOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.PutReader([]byte("baz"), strings.NewReader("streamed"), 8)).To(Succeed())
+		Expect(writer.PutReader([]byte("baz"), strings.NewReader("short"), 8)).To(Equal(errValueSize))
+
+		vw, err := writer.PutWriter([]byte("baz"), 7)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = vw.Write([]byte("written"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vw.Close()).To(Succeed())
+
+		Expect(writer.WriteIndex(filepath.Join(dir, "data.cci"))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		db, err := OpenWithOptions(filepath.Join(dir, "data.cci"), fname, &ReadOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(getValues(db, "baz")).To(Equal([]string{"streamed", "written"}))
+	})
+
+	It("should rotate keys on merge", func() {
+		other := writeLog("other.ccl", 2, "foo", "secret4")
+		dst := filepath.Join(dir, "merged.ccl")
+		Expect(MergeLogsWithOptions(dst, &MergeOptions{Log: &LogOptions{Keys: keys, KeyID: 2}}, fname, other)).To(Succeed())
+
+		db, err := OpenWithOptions(filepath.Join(dir, "merged.cci"), dst, &ReadOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(db.log.Info().KeyID).To(Equal(uint32(2)))
+		Expect(getValues(db, "foo")).To(Equal([]string{"secret1", "secret3", "secret4"}))
+	})
+
+})
@@ -3,6 +3,7 @@ package ccdb
 import (
 	"bytes"
 	"io"
+	"time"
 )
 
 // DB is a read-only abstraction of an index and a log-file combination
@@ -13,23 +14,39 @@ type DB struct {
 
 // Open opens a DB for read-only access
 func Open(indexFileName, logFileName string) (*DB, error) {
+	return OpenWithOptions(indexFileName, logFileName, nil)
+}
+
+// OpenWithOptions opens a DB for read-only access using custom options
+func OpenWithOptions(indexFileName, logFileName string, opt *ReadOptions) (*DB, error) {
 	index, err := OpenIndex(indexFileName)
 	if err != nil {
 		return nil, err
 	}
 
-	log, err := OpenLog(logFileName)
+	log, err := OpenLogWithOptions(logFileName, opt)
 	if err != nil {
 		index.Close()
 		return nil, err
 	}
 
-	if log.header.id != log.header.id {
+	if index.header.id != log.header.id {
 		index.Close()
 		log.Close()
 		return nil, errHeaderDifferent
 	}
 
+	if opt != nil {
+		index.obs = opt.Observer
+	}
+	if opt != nil && opt.PublicKey != nil {
+		if err := verifySignature(index, log, opt.PublicKey); err != nil {
+			index.Close()
+			log.Close()
+			return nil, err
+		}
+	}
+
 	return &DB{index: index, log: log}, nil
 }
 
@@ -42,14 +59,26 @@ func (db *DB) Close() error {
 	return err
 }
 
-// Get retrieves a key and returns a value iterator
+// Info returns information from the log header
+func (db *DB) Info() FileInfo {
+	return db.log.Info()
+}
+
+// Metadata returns the user metadata stored in the log header
+func (db *DB) Metadata() map[string]string {
+	return db.log.Metadata()
+}
+
+// Get retrieves a key and returns a value iterator,
+// skipping values which have expired
 func (db *DB) Get(key []byte) (*Iterator, error) {
-	ii, err := db.index.Seek(key)
-	if err != nil {
-		return nil, err
-	}
+	return db.GetAt(key, time.Now())
+}
 
-	return &Iterator{ii: ii, key: key, log: db.log}, nil
+// GetAt retrieves a key and returns a value iterator,
+// skipping values which have expired as of now
+func (db *DB) GetAt(key []byte, now time.Time) (*Iterator, error) {
+	return newIterator(key, []*DB{db}, now, db.log.obs)
 }
 
 // --------------------------------------------------------------------
@@ -59,11 +88,44 @@ type Iterator struct {
 	ii  *IndexIterator
 	log *LogReader
 	key []byte
+	dbs []*DB // remaining databases
+
+	firstMatch bool // stop after the first database containing the key
+	matched    bool
+	now        time.Time // skip values expired as of now
+
+	obs      Observer // receives the lookup event
+	observed bool
 
 	cur *io.SectionReader
 	err error
 }
 
+func newIterator(key []byte, dbs []*DB, now time.Time, obs Observer) (*Iterator, error) {
+	iter := &Iterator{key: key, dbs: dbs, now: now, obs: obs}
+	if err := iter.seekNext(); err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// seekNext seeks the key in the next remaining database
+func (i *Iterator) seekNext() error {
+	i.ii, i.log = nil, nil
+	if len(i.dbs) == 0 {
+		return nil
+	}
+
+	db := i.dbs[0]
+	ii, err := db.index.Seek(i.key)
+	if err != nil {
+		return err
+	}
+
+	i.ii, i.log, i.dbs = ii, db.log, i.dbs[1:]
+	return nil
+}
+
 // All returns all values
 func (i *Iterator) All() ([][]byte, error) {
 	var vals [][]byte
@@ -83,20 +145,50 @@ func (i *Iterator) Next() bool {
 		return false
 	}
 
-	for i.ii.Next() {
-		key, reader, err := i.log.GetReader(i.ii.Value())
-		if err != nil {
-			i.err = err
+	for i.ii != nil {
+		for i.ii.Next() {
+			ent, reader, err := i.log.entryAt(i.ii.Value())
+			if err != nil {
+				i.err = err
+				return false
+			} else if !bytes.Equal(i.key, ent.Key) {
+				if i.log.obs != nil {
+					i.log.obs.ObserveCollision()
+				}
+			} else if !ent.Expired(i.now) {
+				if reader, err = i.log.valueSection(ent, reader); err != nil {
+					i.err = err
+					return false
+				}
+				i.cur = reader
+				i.matched = true
+				i.observe(true)
+				return true
+			}
+		}
+
+		if i.err = i.ii.Error(); i.err != nil {
+			return false
+		}
+		if i.firstMatch && i.matched {
+			return false
+		}
+		if i.err = i.seekNext(); i.err != nil {
 			return false
-		} else if bytes.Equal(i.key, key) {
-			i.cur = reader
-			return true
 		}
 	}
-	i.err = i.ii.Error()
+	i.observe(false)
 	return false
 }
 
+// observe reports the lookup, once
+func (i *Iterator) observe(hit bool) {
+	if i.obs != nil && !i.observed {
+		i.observed = true
+		i.obs.ObserveLookup(hit)
+	}
+}
+
 // Value returns the value
 func (i *Iterator) Value() ([]byte, error) {
 	if i.cur == nil {
@@ -75,4 +75,21 @@ var _ = Describe("DB", func() {
 		}
 	})
 
+	It("should reject mismatching files", func() {
+		dir := mkTemp()
+		defer os.RemoveAll(dir)
+
+		lname, iname, err := writeTestLogAndIndex(dir, 10)
+		Expect(err).NotTo(HaveOccurred())
+		other, _, err := writeTestWithCollisions(dir, 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = Open(iname, other)
+		Expect(err).To(Equal(errHeaderDifferent))
+
+		db, err := Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.Close()).To(Succeed())
+	})
+
 })
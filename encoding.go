@@ -0,0 +1,70 @@
+package ccdb
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"unicode/utf8"
+)
+
+// Format identifies the file format of imports and exports
+type Format string
+
+// Supported formats
+const (
+	FormatCSV   Format = "csv"   // comma-separated values
+	FormatTSV   Format = "tsv"   // tab-separated values
+	FormatJSONL Format = "jsonl" // JSON lines
+	FormatRaw   Format = "raw"   // length-prefixed binary, export only
+)
+
+// Encoding determines how keys and values are represented in
+// text formats. The zero value is equivalent to EncodingRaw.
+type Encoding string
+
+// Supported encodings
+const (
+	EncodingRaw    Encoding = "raw"
+	EncodingHex    Encoding = "hex"
+	EncodingBase64 Encoding = "base64"
+)
+
+func (e Encoding) validate() error {
+	switch e {
+	case "", EncodingRaw, EncodingHex, EncodingBase64:
+		return nil
+	}
+	return ErrUnknownEncoding
+}
+
+// Decode decodes s
+func (e Encoding) Decode(s string) ([]byte, error) {
+	switch e {
+	case "", EncodingRaw:
+		return []byte(s), nil
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return nil, ErrUnknownEncoding
+}
+
+// EncodeJSON encodes b for JSON strings, which cannot carry
+// raw binary data. Returns ErrInvalidUTF8 for such data.
+func (e Encoding) EncodeJSON(b []byte) (string, error) {
+	if (e == "" || e == EncodingRaw) && !utf8.Valid(b) {
+		return "", ErrInvalidUTF8
+	}
+	return e.Encode(b), nil
+}
+
+// Encode encodes b
+func (e Encoding) Encode(b []byte) string {
+	switch e {
+	case EncodingHex:
+		return hex.EncodeToString(b)
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return string(b)
+}
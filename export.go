@@ -0,0 +1,219 @@
+package ccdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// ExportOptions configure exports
+type ExportOptions struct {
+	// Format of the output. Default: FormatJSONL
+	Format Format
+
+	// KeyEncoding and ValueEncoding determine how keys and values
+	// are encoded in text formats. Default: EncodingRaw. Raw JSON lines
+	// exports fail with ErrInvalidUTF8 on keys or values which are not
+	// valid UTF-8.
+	KeyEncoding, ValueEncoding Encoding
+
+	// Offsets includes the offsets of entries, as a "pos" field in JSON
+	// lines, as a third column in CSV and TSV and as a uvarint prefix
+	// in raw output.
+	Offsets bool
+
+	// Header writes a header row to CSV and TSV output.
+	Header bool
+
+	// Prefix only exports entries with matching keys.
+	Prefix []byte
+
+	// Start and End limit the export to entries starting within
+	// [Start, End). Start must be the offset of an entry, End may be any
+	// offset. Default: all entries
+	Start, End int64
+}
+
+func (o *ExportOptions) norm() (*ExportOptions, error) {
+	var opt ExportOptions
+	if o != nil {
+		opt = *o
+	}
+
+	switch opt.Format {
+	case "":
+		opt.Format = FormatJSONL
+	case FormatCSV, FormatTSV, FormatJSONL, FormatRaw:
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err := opt.KeyEncoding.validate(); err != nil {
+		return nil, err
+	}
+	if err := opt.ValueEncoding.validate(); err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
+
+// Export writes the entries of a log to w. The raw format writes each
+// entry as a uvarint key length, a uvarint value length, the key and
+// the value. Returns the number of exported entries.
+func Export(r *LogReader, w io.Writer, opt *ExportOptions) (int64, error) {
+	opt, err := opt.norm()
+	if err != nil {
+		return 0, err
+	}
+
+	start, end := opt.Start, opt.End
+	if start == 0 {
+		start = r.header.dataOffset()
+	}
+	if end == 0 || end > r.header.pos {
+		end = r.header.pos
+	}
+	if start < r.header.dataOffset() || start > end {
+		return 0, errInvalidOffset
+	}
+
+	bw := bufio.NewWriter(w)
+	var enc exportEncoder
+	switch opt.Format {
+	case FormatCSV, FormatTSV:
+		enc = newCSVExporter(bw, opt)
+	case FormatRaw:
+		enc = &rawExporter{w: bw, opt: opt}
+	default:
+		enc = &jsonExporter{enc: json.NewEncoder(bw), opt: opt}
+	}
+
+	if err := enc.begin(); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	// iterate beyond end, which may fall within an entry
+	iter := newLogIteratorAt(r.src, r.header, start, r.header.pos)
+	iter.aead = r.aead
+	for iter.Next() {
+		ent := iter.Entry()
+		if ent.Pos >= end {
+			break
+		}
+		if !bytes.HasPrefix(ent.Key, opt.Prefix) {
+			continue
+		}
+		if err := enc.encode(ent); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := iter.Error(); err != nil {
+		return n, err
+	}
+	if err := enc.end(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+type exportEncoder interface {
+	begin() error
+	encode(*Entry) error
+	end() error
+}
+
+type jsonExporter struct {
+	enc *json.Encoder
+	opt *ExportOptions
+}
+
+type jsonRecord struct {
+	Pos   *int64 `json:"pos,omitempty"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (e *jsonExporter) begin() error { return nil }
+func (e *jsonExporter) end() error   { return nil }
+func (e *jsonExporter) encode(ent *Entry) error {
+	var rec jsonRecord
+	var err error
+	if rec.Key, err = e.opt.KeyEncoding.EncodeJSON(ent.Key); err != nil {
+		return err
+	}
+	if rec.Value, err = e.opt.ValueEncoding.EncodeJSON(ent.Val); err != nil {
+		return err
+	}
+	if e.opt.Offsets {
+		rec.Pos = &ent.Pos
+	}
+	return e.enc.Encode(&rec)
+}
+
+type csvExporter struct {
+	w   *csv.Writer
+	opt *ExportOptions
+	rec []string
+}
+
+func newCSVExporter(w io.Writer, opt *ExportOptions) *csvExporter {
+	cw := csv.NewWriter(w)
+	if opt.Format == FormatTSV {
+		cw.Comma = '\t'
+	}
+	return &csvExporter{w: cw, opt: opt}
+}
+
+func (e *csvExporter) begin() error {
+	if !e.opt.Header {
+		return nil
+	}
+	if e.opt.Offsets {
+		return e.w.Write([]string{"key", "value", "pos"})
+	}
+	return e.w.Write([]string{"key", "value"})
+}
+
+func (e *csvExporter) encode(ent *Entry) error {
+	e.rec = append(e.rec[:0], e.opt.KeyEncoding.Encode(ent.Key), e.opt.ValueEncoding.Encode(ent.Val))
+	if e.opt.Offsets {
+		e.rec = append(e.rec, strconv.FormatInt(ent.Pos, 10))
+	}
+	return e.w.Write(e.rec)
+}
+
+func (e *csvExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type rawExporter struct {
+	w   *bufio.Writer
+	opt *ExportOptions
+	buf [3 * binary.MaxVarintLen64]byte
+}
+
+func (e *rawExporter) begin() error { return nil }
+func (e *rawExporter) end() error   { return nil }
+func (e *rawExporter) encode(ent *Entry) error {
+	n := 0
+	if e.opt.Offsets {
+		n += binary.PutUvarint(e.buf[n:], uint64(ent.Pos))
+	}
+	n += binary.PutUvarint(e.buf[n:], uint64(len(ent.Key)))
+	n += binary.PutUvarint(e.buf[n:], uint64(len(ent.Val)))
+
+	if _, err := e.w.Write(e.buf[:n]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(ent.Key); err != nil {
+		return err
+	}
+	_, err := e.w.Write(ent.Val)
+	return err
+}
@@ -0,0 +1,124 @@
+package ccdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var dir string
+	var reader *LogReader
+
+	var export = func(opt *ExportOptions) (string, int64) {
+		buf := new(bytes.Buffer)
+		n, err := Export(reader, buf, opt)
+		Expect(err).NotTo(HaveOccurred())
+		return buf.String(), n
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname := filepath.Join(dir, "test.ccl")
+
+		writer, err := CreateLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		for _, kv := range [][]string{{"foo", "v1"}, {"bar", "v,2"}, {"foo", "v3"}} {
+			Expect(writer.Put([]byte(kv[0]), []byte(kv[1]))).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+
+		reader, err = OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		reader.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should export JSON lines", func() {
+		out, n := export(nil)
+		Expect(n).To(Equal(int64(3)))
+		Expect(out).To(Equal(`{"key":"foo","value":"v1"}` + "\n" + `{"key":"bar","value":"v,2"}` + "\n" + `{"key":"foo","value":"v3"}` + "\n"))
+
+		out, _ = export(&ExportOptions{Offsets: true, KeyEncoding: EncodingHex, ValueEncoding: EncodingBase64})
+		Expect(out).To(HavePrefix(`{"pos":128,"key":"666f6f","value":"djE="}` + "\n" + `{"pos":135,"key":"626172","value":"diwy"}` + "\n"))
+	})
+
+	It("should export CSV and TSV", func() {
+		out, _ := export(&ExportOptions{Format: FormatCSV, Header: true})
+		Expect(out).To(Equal("key,value\nfoo,v1\nbar,\"v,2\"\nfoo,v3\n"))
+
+		out, _ = export(&ExportOptions{Format: FormatTSV, Offsets: true})
+		Expect(out).To(Equal("foo\tv1\t128\nbar\tv,2\t135\nfoo\tv3\t143\n"))
+	})
+
+	It("should export raw streams", func() {
+		out, _ := export(&ExportOptions{Format: FormatRaw})
+		Expect(out).To(Equal("\x03\x02foov1\x03\x03barv,2\x03\x02foov3"))
+
+		out, _ = export(&ExportOptions{Format: FormatRaw, Offsets: true, Prefix: []byte("b")})
+		Expect(out).To(Equal("\x87\x01\x03\x03barv,2"))
+	})
+
+	It("should filter", func() {
+		out, n := export(&ExportOptions{Format: FormatCSV, Prefix: []byte("fo")})
+		Expect(n).To(Equal(int64(2)))
+		Expect(out).To(Equal("foo,v1\nfoo,v3\n"))
+
+		out, n = export(&ExportOptions{Format: FormatCSV, Start: 135, End: 143})
+		Expect(n).To(Equal(int64(1)))
+		Expect(out).To(Equal("bar,\"v,2\"\n"))
+
+		out, _ = export(&ExportOptions{Format: FormatCSV, Start: 143, End: 1000})
+		Expect(out).To(Equal("foo,v3\n"))
+
+		out, n = export(&ExportOptions{Format: FormatCSV, End: 130})
+		Expect(n).To(Equal(int64(1)))
+		Expect(out).To(Equal("foo,v1\n"))
+	})
+
+	It("should reject binary data in raw JSON lines", func() {
+		writer, err := AppendLog(reader.file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Put([]byte("bin"), []byte{0xff, 0xfe, 0})).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		reader.Close()
+		reader, err = OpenLog(writer.file.Name())
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = Export(reader, new(bytes.Buffer), nil)
+		Expect(err).To(Equal(ErrInvalidUTF8))
+
+		out, _ := export(&ExportOptions{Prefix: []byte("bin"), ValueEncoding: EncodingBase64})
+		Expect(out).To(Equal(`{"key":"bin","value":"//4A"}` + "\n"))
+	})
+
+	It("should validate options", func() {
+		_, err := Export(reader, new(bytes.Buffer), &ExportOptions{Format: "xml"})
+		Expect(err).To(Equal(ErrUnknownFormat))
+
+		_, err = Export(reader, new(bytes.Buffer), &ExportOptions{Start: 100})
+		Expect(err).To(Equal(errInvalidOffset))
+	})
+
+	It("should round-trip with imports", func() {
+		out, _ := export(&ExportOptions{ValueEncoding: EncodingBase64})
+
+		fname := filepath.Join(dir, "copy.ccl")
+		writer, err := CreateLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer writer.Close()
+
+		report, err := Import(writer, bytes.NewBufferString(out), &ImportOptions{Format: FormatJSONL, ValueEncoding: EncodingBase64})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Imported).To(Equal(3))
+		Expect(writer.Size()).To(Equal(reader.Info().Size))
+	})
+
+})
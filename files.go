@@ -2,13 +2,27 @@ package ccdb
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 )
 
-const fileHeaderLen = 128
+const (
+	fileHeaderLen = 128
+
+	// metadata is stored in the otherwise unused tail of the header
+	metadataOffset = 24
+	maxMetadataLen = fileHeaderLen - metadataOffset
+
+	// larger metadata is stored in a length-prefixed section, which
+	// follows the header and precedes the data
+	maxExtendedMetadataLen = 64 * 1024
+	maxFileHeaderLen       = fileHeaderLen + 4 + maxExtendedMetadataLen
+)
 
 // --------------------------------------------------------------------
 
@@ -51,29 +65,112 @@ func (r *fileReader) Close() error {
 	return nil
 }
 
+// Info returns information from the file header
+func (r *fileReader) Info() FileInfo { return r.header.info() }
+
+// Metadata returns the user metadata stored in the file header
+func (r *fileReader) Metadata() map[string]string { return r.header.metadata() }
+
+// --------------------------------------------------------------------
+
+// FileInfo contains information from a file header
+type FileInfo struct {
+	MajorVersion uint16 `json:"major_version"`
+	MinorVersion uint16 `json:"minor_version"`
+	ID           uint32 `json:"id"`
+	Size         int64  `json:"size"`
+	Timestamps   bool   `json:"timestamps,omitempty"`
+	Encrypted    bool   `json:"encrypted,omitempty"`
+	KeyID        uint32 `json:"key_id,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (i FileInfo) String() string {
+	s := fmt.Sprintf("Version %d.%d\nIdentifier: %08x\nSize: %d\n", i.MajorVersion, i.MinorVersion, i.ID, i.Size)
+	if i.Timestamps {
+		s += "Timestamps: yes\n"
+	}
+	if i.Encrypted {
+		s += fmt.Sprintf("Encryption key: %d\n", i.KeyID)
+	}
+	for _, key := range sortedKeys(i.Metadata) {
+		s += fmt.Sprintf("Metadata: %s=%s\n", key, i.Metadata[key])
+	}
+	return s
+}
+
 // --------------------------------------------------------------------
 
 type fileHeader struct {
 	version
-	id  uint32
-	pos int64
+	id    uint32
+	pos   int64
+	flags uint16
+	keyID uint32 // encryption key ID
+	meta  []byte // encoded metadata
 }
 
-func newFileHeader() *fileHeader {
-	id := rand.Uint32()
-	for id == 0 {
-		id = rand.Uint32()
-	}
+// newFileHeader creates a header for a plain file, which
+// remains readable by readers supporting version 1.0
+func newFileHeader(id uint32) *fileHeader {
 	return &fileHeader{
 		id:      id,
-		version: version{majorVersion, minorVersion},
+		version: version{majorVersion, 0},
 		pos:     fileHeaderLen,
 	}
 }
 
+// setFlags enables format features, which require minor version 1,
+// extended headers require minor version 2
+func (h *fileHeader) setFlags(flags uint16) {
+	h.flags |= flags
+	if h.extended() && h.minor < 2 {
+		h.minor = 2
+	} else if h.flags != 0 && h.minor < 1 {
+		h.minor = 1
+	}
+}
+
+// setMetadata stores encoded metadata, in an extended
+// header section if necessary, must be called before data is written
+func (h *fileHeader) setMetadata(meta []byte) {
+	h.meta = meta
+	if len(meta) > maxMetadataLen {
+		h.setFlags(flagExtended)
+	}
+	h.pos = h.dataOffset()
+}
+
+func (h *fileHeader) timestamps() bool { return h.flags&flagTimestamps != 0 }
+func (h *fileHeader) encrypted() bool  { return h.flags&flagEncrypted != 0 }
+func (h *fileHeader) extended() bool   { return h.flags&flagExtended != 0 }
+
+// dataOffset returns the offset of the first entry, after the header
+// and the extended header section
+func (h *fileHeader) dataOffset() int64 {
+	if h.extended() {
+		return fileHeaderLen + 4 + int64(len(h.meta))
+	}
+	return fileHeaderLen
+}
+
+// randomFileID reads a random, non-zero file ID from src
+func randomFileID(src io.Reader) (uint32, error) {
+	buf := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(src, buf); err != nil {
+			return 0, err
+		}
+		if id := binary.LittleEndian.Uint32(buf); id != 0 {
+			return id, nil
+		}
+	}
+}
+
 func readFileHeader(r io.Reader) (*fileHeader, error) {
 	buf := make([]byte, fileHeaderLen)
-	if _, err := r.Read(buf); err == io.EOF {
+	if _, err := io.ReadFull(r, buf); err == io.EOF || err == io.ErrUnexpectedEOF {
 		return nil, errHeaderCorrupt
 	} else if err != nil {
 		return nil, err
@@ -84,28 +181,196 @@ func readFileHeader(r io.Reader) (*fileHeader, error) {
 		return nil, errBadMagic
 	} else if h.major = binary.LittleEndian.Uint16(buf[2:]); h.major != majorVersion {
 		return nil, errWrongMajorVersion
-	} else if h.minor = binary.LittleEndian.Uint16(buf[4:]); h.minor < minorVersion {
-		return nil, errWrongMajorVersion
+	} else if h.minor = binary.LittleEndian.Uint16(buf[4:]); h.minor > minorVersion {
+		return nil, errUnsupportedMinorVersion
 	} else if h.id = binary.LittleEndian.Uint32(buf[6:]); h.id == 0 {
 		return nil, errBadFileID
-	} else if h.pos = int64(binary.LittleEndian.Uint64(buf[10:])); h.pos < fileHeaderLen {
+	} else if h.flags = binary.LittleEndian.Uint16(buf[18:]); h.flags&^knownFlags != 0 {
+		return nil, errUnsupportedMinorVersion
+	}
+	h.pos = int64(binary.LittleEndian.Uint64(buf[10:]))
+	h.keyID = binary.LittleEndian.Uint32(buf[20:])
+
+	if h.extended() {
+		meta, err := readExtendedMetadata(r)
+		if err != nil {
+			return nil, err
+		}
+		h.meta = meta
+	} else if meta := buf[metadataOffset:]; meta[0] != 0 {
+		if _, err := decodeMetadata(meta); err != nil {
+			return nil, errHeaderCorrupt
+		}
+		h.meta = meta
+	}
+
+	if h.pos < h.dataOffset() {
 		return nil, errHeaderCorrupt
 	}
 	return &h, nil
 }
 
+// readExtendedMetadata reads the length-prefixed extended header section
+func readExtendedMetadata(r io.Reader) ([]byte, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, errHeaderCorrupt
+	} else if err != nil {
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(buf)
+	if size <= maxMetadataLen || size > maxExtendedMetadataLen {
+		return nil, errHeaderCorrupt
+	}
+
+	meta := make([]byte, int(size))
+	if _, err := io.ReadFull(r, meta); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, errHeaderCorrupt
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := decodeMetadata(meta); err != nil {
+		return nil, errHeaderCorrupt
+	}
+	return meta, nil
+}
+
+func (h *fileHeader) info() FileInfo {
+	return FileInfo{
+		MajorVersion: h.major,
+		MinorVersion: h.minor,
+		ID:           h.id,
+		Size:         h.pos,
+		Timestamps:   h.timestamps(),
+		Encrypted:    h.encrypted(),
+		KeyID:        h.keyID,
+		Metadata:     h.metadata(),
+	}
+}
+
+func (h *fileHeader) metadata() map[string]string {
+	meta, _ := decodeMetadata(h.meta)
+	return meta
+}
+
 func (h *fileHeader) String() string {
-	return fmt.Sprintf("Version %d.%d\nIdentifier: %08x\nSize: %d\n", h.major, h.minor, h.id, h.pos)
+	return h.info().String()
 }
 
+// WriteTo writes the header, including the extended header section
 func (h *fileHeader) WriteTo(w io.Writer) (int64, error) {
-	buf := make([]byte, fileHeaderLen)
+	buf := make([]byte, h.dataOffset())
 	binary.LittleEndian.PutUint16(buf[0:], magicNumber)
 	binary.LittleEndian.PutUint16(buf[2:], majorVersion)
-	binary.LittleEndian.PutUint16(buf[4:], minorVersion)
+	binary.LittleEndian.PutUint16(buf[4:], h.minor)
 	binary.LittleEndian.PutUint32(buf[6:], h.id)
 	binary.LittleEndian.PutUint64(buf[10:], uint64(h.pos))
+	binary.LittleEndian.PutUint16(buf[18:], h.flags)
+	binary.LittleEndian.PutUint32(buf[20:], h.keyID)
+	if h.extended() {
+		binary.LittleEndian.PutUint32(buf[fileHeaderLen:], uint32(len(h.meta)))
+		copy(buf[fileHeaderLen+4:], h.meta)
+	} else {
+		copy(buf[metadataOffset:], h.meta)
+	}
 
 	n, err := w.Write(buf)
 	return int64(n), err
 }
+
+// --------------------------------------------------------------------
+
+// encodeMetadata encodes metadata as a count, followed by length-prefixed
+// keys and values, sorted by key
+func encodeMetadata(meta map[string]string) ([]byte, error) {
+	if len(meta) == 0 {
+		return nil, nil
+	}
+
+	var buf []byte
+	buf = appendUvarint(buf, uint64(len(meta)))
+	for _, key := range sortedKeys(meta) {
+		val := meta[key]
+		buf = appendUvarint(buf, uint64(len(key)))
+		buf = append(buf, key...)
+		buf = appendUvarint(buf, uint64(len(val)))
+		buf = append(buf, val...)
+	}
+	if len(buf) > maxExtendedMetadataLen {
+		return nil, ErrMetadataTooLarge
+	}
+	return buf, nil
+}
+
+func decodeMetadata(buf []byte) (map[string]string, error) {
+	count, n := binary.Uvarint(buf)
+	if n <= 0 || count > uint64(len(buf)) {
+		return nil, errHeaderCorrupt
+	} else if count == 0 {
+		return nil, nil
+	}
+	buf = buf[n:]
+
+	next := func() (string, bool) {
+		size, n := binary.Uvarint(buf)
+		if n <= 0 || size > uint64(len(buf)-n) {
+			return "", false
+		}
+		s := string(buf[n : n+int(size)])
+		buf = buf[n+int(size):]
+		return s, true
+	}
+
+	meta := make(map[string]string, int(count))
+	for i := uint64(0); i < count; i++ {
+		key, ok := next()
+		if !ok {
+			return nil, errHeaderCorrupt
+		}
+		val, ok := next()
+		if !ok {
+			return nil, errHeaderCorrupt
+		}
+		meta[key] = val
+	}
+	return meta, nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func sortedKeys(meta map[string]string) []string {
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// --------------------------------------------------------------------
+
+// writeJSONFile atomically replaces fname with the JSON encoding of v
+func writeJSONFile(fname string, v interface{}) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fname), filepath.Base(fname)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fname)
+}
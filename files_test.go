@@ -2,7 +2,9 @@ package ccdb
 
 import (
 	"bytes"
+	"io"
 	"os"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -22,6 +24,13 @@ var _ = Describe("fileReader", func() {
 		defer reader.Close()
 
 		Expect(reader.header).NotTo(BeNil())
+		Expect(reader.Info()).To(Equal(FileInfo{
+			MajorVersion: majorVersion,
+			MinorVersion: 0,
+			ID:           reader.header.id,
+			Size:         149,
+		}))
+		Expect(reader.Info().String()).To(Equal(reader.header.String()))
 	})
 
 })
@@ -30,11 +39,19 @@ var _ = Describe("fileHeader", func() {
 	var subject *fileHeader
 
 	BeforeEach(func() {
-		subject = newFileHeader()
-		subject.id = 74682
+		subject = newFileHeader(74682)
 		subject.pos = 8096
 	})
 
+	It("should generate random IDs", func() {
+		id, err := randomFileID(bytes.NewReader([]byte{0, 0, 0, 0, 1, 2, 3, 4}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(uint32(0x04030201)))
+
+		_, err = randomFileID(bytes.NewReader([]byte{0, 0, 0, 0}))
+		Expect(err).To(Equal(io.EOF))
+	})
+
 	It("should dump and load", func() {
 		buf := &bytes.Buffer{}
 		n, err := subject.WriteTo(buf)
@@ -44,10 +61,74 @@ var _ = Describe("fileHeader", func() {
 		read, err := readFileHeader(bytes.NewReader(buf.Bytes()))
 		Expect(err).NotTo(HaveOccurred())
 		Expect(read).To(Equal(&fileHeader{
-			version: version{majorVersion, minorVersion},
+			version: version{majorVersion, 0},
 			id:      74682,
 			pos:     8096,
 		}))
 	})
 
+	It("should dump and load flags", func() {
+		subject.setFlags(flagTimestamps)
+		Expect(subject.minor).To(Equal(uint16(1)))
+
+		buf := &bytes.Buffer{}
+		_, err := subject.WriteTo(buf)
+		Expect(err).NotTo(HaveOccurred())
+
+		read, err := readFileHeader(bytes.NewReader(buf.Bytes()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read.timestamps()).To(BeTrue())
+		Expect(read.info().MinorVersion).To(Equal(uint16(1)))
+
+		subject.minor = minorVersion + 1
+		buf.Reset()
+		_, err = subject.WriteTo(buf)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = readFileHeader(bytes.NewReader(buf.Bytes()))
+		Expect(err).To(Equal(errUnsupportedMinorVersion))
+	})
+
+	It("should dump and load metadata", func() {
+		var err error
+		subject.meta, err = encodeMetadata(map[string]string{"b": "2", "a": "1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.meta).To(Equal([]byte{2, 1, 'a', 1, '1', 1, 'b', 1, '2'}))
+
+		buf := &bytes.Buffer{}
+		_, err = subject.WriteTo(buf)
+		Expect(err).NotTo(HaveOccurred())
+
+		read, err := readFileHeader(bytes.NewReader(buf.Bytes()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read.metadata()).To(Equal(map[string]string{"a": "1", "b": "2"}))
+		Expect(read.String()).To(HaveSuffix("Metadata: a=1\nMetadata: b=2\n"))
+
+		corrupt := buf.Bytes()
+		corrupt[metadataOffset+1] = 200
+		_, err = readFileHeader(bytes.NewReader(corrupt))
+		Expect(err).To(Equal(errHeaderCorrupt))
+	})
+
+	It("should dump and load extended metadata", func() {
+		meta, err := encodeMetadata(map[string]string{"a": strings.Repeat("x", 200)})
+		Expect(err).NotTo(HaveOccurred())
+		subject.setMetadata(meta)
+		Expect(subject.minor).To(Equal(uint16(2)))
+		Expect(subject.pos).To(Equal(int64(fileHeaderLen + 4 + 205)))
+
+		buf := &bytes.Buffer{}
+		n, err := subject.WriteTo(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(subject.pos))
+
+		read, err := readFileHeader(bytes.NewReader(buf.Bytes()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read.extended()).To(BeTrue())
+		Expect(read.dataOffset()).To(Equal(subject.pos))
+		Expect(read.metadata()).To(Equal(map[string]string{"a": strings.Repeat("x", 200)}))
+
+		_, err = readFileHeader(bytes.NewReader(buf.Bytes()[:fileHeaderLen+10]))
+		Expect(err).To(Equal(errHeaderCorrupt))
+	})
+
 })
@@ -0,0 +1,290 @@
+package ccdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxImportErrors limits the number of errors in import reports
+const maxImportErrors = 100
+
+// ImportOptions configure imports
+type ImportOptions struct {
+	// Format of the input. Default: FormatCSV
+	Format Format
+
+	// KeyColumn and ValueColumn select the 1-based columns
+	// of CSV and TSV rows. Default: 1 and 2
+	KeyColumn, ValueColumn int
+
+	// SkipHeader skips the first row of CSV and TSV input.
+	SkipHeader bool
+
+	// KeyField and ValueField select the fields of JSON lines, either
+	// by name or as a JSON pointer, i.e. "/user/id". Non-string values
+	// are imported as JSON. Default: "key" and "value"
+	KeyField, ValueField string
+
+	// KeyEncoding and ValueEncoding determine how keys and string values
+	// are decoded. Default: EncodingRaw
+	KeyEncoding, ValueEncoding Encoding
+
+	// SkipInvalid skips and reports invalid rows, instead of failing.
+	SkipInvalid bool
+
+	// Index writes an index to the given path, once all rows are imported.
+	Index string
+}
+
+func (o *ImportOptions) norm() (*ImportOptions, error) {
+	var opt ImportOptions
+	if o != nil {
+		opt = *o
+	}
+
+	switch opt.Format {
+	case "":
+		opt.Format = FormatCSV
+	case FormatCSV, FormatTSV, FormatJSONL:
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err := opt.KeyEncoding.validate(); err != nil {
+		return nil, err
+	}
+	if err := opt.ValueEncoding.validate(); err != nil {
+		return nil, err
+	}
+	if opt.KeyColumn <= 0 {
+		opt.KeyColumn = 1
+	}
+	if opt.ValueColumn <= 0 {
+		opt.ValueColumn = 2
+	}
+	if opt.KeyField == "" {
+		opt.KeyField = "key"
+	}
+	if opt.ValueField == "" {
+		opt.ValueField = "value"
+	}
+	return &opt, nil
+}
+
+// ImportReport describes the outcome of an import
+type ImportReport struct {
+	Rows     int            `json:"rows"`             // number of rows read
+	Imported int            `json:"imported"`         // number of imported rows
+	Skipped  int            `json:"skipped"`          // number of skipped rows
+	Errors   []*ImportError `json:"errors,omitempty"` // errors of (up to 100) skipped rows
+}
+
+func (r *ImportReport) String() string {
+	s := fmt.Sprintf("Rows: %d\nImported: %d\nSkipped: %d\n", r.Rows, r.Imported, r.Skipped)
+	for _, e := range r.Errors {
+		s += "Error: " + e.Error() + "\n"
+	}
+	return s
+}
+
+// ImportError is returned for invalid rows
+type ImportError struct {
+	Row     int    `json:"row"` // 1-based row (CSV/TSV record or line) number
+	Message string `json:"message"`
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// Import reads key/value pairs from r and appends them to the log.
+func Import(w *LogWriter, r io.Reader, opt *ImportOptions) (*ImportReport, error) {
+	opt, err := opt.norm()
+	if err != nil {
+		return nil, err
+	}
+
+	report := new(ImportReport)
+	put := func(row int, key, val []byte, err error) error {
+		report.Rows++
+		if err == nil {
+			err = w.validate(key, int64(len(val)))
+		}
+		if err == nil {
+			if err := w.Put(key, val); err != nil {
+				return err
+			}
+			report.Imported++
+			return nil
+		}
+
+		ierr := &ImportError{Row: row, Message: err.Error()}
+		if !opt.SkipInvalid {
+			return ierr
+		}
+		report.Skipped++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, ierr)
+		}
+		return nil
+	}
+
+	if opt.Format == FormatJSONL {
+		err = importJSONL(r, opt, put)
+	} else {
+		err = importCSV(r, opt, put)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	if opt.Index != "" {
+		if err := w.WriteIndex(opt.Index); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+type importFunc func(row int, key, val []byte, err error) error
+
+func importCSV(r io.Reader, opt *ImportOptions, put importFunc) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	if opt.Format == FormatTSV {
+		cr.Comma = '\t'
+		cr.LazyQuotes = true
+	}
+
+	for row := 1; ; row++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		} else if _, ok := err.(*csv.ParseError); !ok && err != nil {
+			return err
+		}
+		if row == 1 && opt.SkipHeader {
+			continue
+		}
+
+		var key, val []byte
+		if err == nil {
+			key, val, err = decodeColumns(rec, opt)
+		}
+		if err := put(row, key, val, err); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeColumns(rec []string, opt *ImportOptions) ([]byte, []byte, error) {
+	if opt.KeyColumn > len(rec) || opt.ValueColumn > len(rec) {
+		return nil, nil, errMissingColumn
+	}
+
+	key, err := opt.KeyEncoding.Decode(rec[opt.KeyColumn-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	val, err := opt.ValueEncoding.Decode(rec[opt.ValueColumn-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, val, nil
+}
+
+func importJSONL(r io.Reader, opt *ImportOptions, put importFunc) error {
+	br := bufio.NewReader(r)
+	for row := 1; ; row++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		// blank lines are ignored
+		if len(bytes.TrimSpace(line)) != 0 {
+			key, val, rerr := decodeJSONLine(line, opt)
+			if err := put(row, key, val, rerr); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func decodeJSONLine(line []byte, opt *ImportOptions) ([]byte, []byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil, err
+	}
+
+	key, err := jsonField(doc, opt.KeyField, opt.KeyEncoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	val, err := jsonField(doc, opt.ValueField, opt.ValueEncoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, val, nil
+}
+
+// jsonField extracts a field from a JSON document, string values are
+// decoded using enc, other values are returned as JSON
+func jsonField(doc interface{}, field string, enc Encoding) ([]byte, error) {
+	v, ok := lookupJSON(doc, field)
+	if !ok || v == nil {
+		return nil, errMissingField
+	}
+	if s, ok := v.(string); ok {
+		return enc.Decode(s)
+	}
+	return json.Marshal(v)
+}
+
+// lookupJSON looks up a field by name or by JSON pointer (RFC 6901)
+func lookupJSON(doc interface{}, field string) (interface{}, bool) {
+	if !strings.HasPrefix(field, "/") {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok := obj[field]
+		return v, ok
+	}
+
+	for _, token := range strings.Split(field[1:], "/") {
+		token = jsonPointerUnescaper.Replace(token)
+
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, false
+			}
+			doc = v
+		case []interface{}:
+			n, err := strconv.Atoi(token)
+			if err != nil || n < 0 || n >= len(node) {
+				return nil, false
+			}
+			doc = node[n]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
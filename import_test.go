@@ -0,0 +1,138 @@
+package ccdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Import", func() {
+	var dir, fname string
+	var writer *LogWriter
+
+	var dump = func() []string {
+		Expect(writer.Flush()).To(Succeed())
+
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		var acc []string
+		iter := reader.Iterator()
+		for iter.Next() {
+			ent := iter.Entry()
+			acc = append(acc, string(ent.Key)+"="+string(ent.Val))
+		}
+		Expect(iter.Error()).NotTo(HaveOccurred())
+		return acc
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = filepath.Join(dir, "test.ccl")
+
+		var err error
+		writer, err = CreateLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		writer.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should import CSV", func() {
+		report, err := Import(writer, strings.NewReader("id,name,value\n1,foo,\"v,1\"\n2,bar,v2\n"), &ImportOptions{
+			KeyColumn:   2,
+			ValueColumn: 3,
+			SkipHeader:  true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(Equal(&ImportReport{Rows: 2, Imported: 2}))
+		Expect(dump()).To(Equal([]string{"foo=v,1", "bar=v2"}))
+	})
+
+	It("should import TSV", func() {
+		report, err := Import(writer, strings.NewReader("666f6f\tdjE=\n626172\t\"v2\n"), &ImportOptions{
+			Format:        FormatTSV,
+			KeyEncoding:   EncodingHex,
+			ValueEncoding: EncodingBase64,
+			SkipInvalid:   true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Rows).To(Equal(2))
+		Expect(report.Imported).To(Equal(1))
+		Expect(report.Errors).To(HaveLen(1))
+		Expect(report.Errors[0].Row).To(Equal(2))
+		Expect(dump()).To(Equal([]string{"foo=v1"}))
+	})
+
+	It("should import JSON lines", func() {
+		input := `{"key":"foo","value":"v1"}` + "\n\n" +
+			`{"key":"bar","value":{"a":[1,2.50]}}` + "\n" +
+			`{"key":"baz","value":true}`
+		report, err := Import(writer, strings.NewReader(input), &ImportOptions{Format: FormatJSONL})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(Equal(&ImportReport{Rows: 3, Imported: 3}))
+		Expect(dump()).To(Equal([]string{"foo=v1", `bar={"a":[1,2.50]}`, "baz=true"}))
+	})
+
+	It("should import JSON pointers", func() {
+		input := `{"user":{"ids":["u1","u2"]},"a/b":{"c~d":"v1"}}` + "\n"
+		report, err := Import(writer, strings.NewReader(input), &ImportOptions{
+			Format:     FormatJSONL,
+			KeyField:   "/user/ids/1",
+			ValueField: "/a~1b/c~0d",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Imported).To(Equal(1))
+		Expect(dump()).To(Equal([]string{"u2=v1"}))
+	})
+
+	It("should skip invalid rows", func() {
+		input := `{"key":"foo","value":"v1"}` + "\n" +
+			`{"key":"bar"}` + "\n" +
+			`{"key":"",` + "\n" +
+			`{"key":"","value":"v4"}` + "\n" +
+			`{"key":"baz","value":"v5"}` + "\n"
+
+		_, err := Import(writer, strings.NewReader(input), &ImportOptions{Format: FormatJSONL})
+		Expect(err).To(Equal(&ImportError{Row: 2, Message: "ccdb: missing field"}))
+		Expect(err).To(MatchError("row 2: ccdb: missing field"))
+
+		report, err := Import(writer, strings.NewReader(input), &ImportOptions{Format: FormatJSONL, SkipInvalid: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Rows).To(Equal(5))
+		Expect(report.Imported).To(Equal(2))
+		Expect(report.Skipped).To(Equal(3))
+		Expect(report.String()).To(HavePrefix("Rows: 5\nImported: 2\nSkipped: 3\nError: row 2: ccdb: missing field\nError: row 3: "))
+		Expect(report.Errors[2]).To(Equal(&ImportError{Row: 4, Message: "ccdb: keys must not be blank"}))
+		Expect(dump()).To(Equal([]string{"foo=v1", "foo=v1", "baz=v5"}))
+	})
+
+	It("should write indexes", func() {
+		iname := filepath.Join(dir, "test.cci")
+		_, err := Import(writer, strings.NewReader("foo,v1\nbar,v2\n"), &ImportOptions{Index: iname})
+		Expect(err).NotTo(HaveOccurred())
+
+		db, err := Open(iname, fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		iter, err := db.Get([]byte("bar"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.All()).To(Equal([][]byte{[]byte("v2")}))
+	})
+
+	It("should validate options", func() {
+		_, err := Import(writer, strings.NewReader(""), &ImportOptions{Format: "xml"})
+		Expect(err).To(Equal(ErrUnknownFormat))
+
+		_, err = Import(writer, strings.NewReader(""), &ImportOptions{KeyEncoding: "rot13"})
+		Expect(err).To(Equal(ErrUnknownEncoding))
+	})
+
+})
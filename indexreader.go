@@ -8,6 +8,7 @@ import (
 // IndexReader can search index files for log offsets
 type IndexReader struct {
 	*fileReader
+	obs Observer
 }
 
 // OpenIndex opens an index file for reading/searching. Example:
@@ -18,7 +19,7 @@ func OpenIndex(fname string) (*IndexReader, error) {
 		return nil, err
 	}
 
-	return &IndexReader{reader}, nil
+	return &IndexReader{fileReader: reader}, nil
 }
 
 // Seek returns an log-offset iterator
@@ -37,6 +38,7 @@ func (i *IndexReader) Seek(key []byte) (*IndexIterator, error) {
 		offset: offset,
 		nslots: nslots,
 		tbuf:   tbuf,
+		obs:    i.obs,
 	}
 	if nslots > 0 {
 		iter.cursor = cksum.Slot() % nslots
@@ -45,7 +47,7 @@ func (i *IndexReader) Seek(key []byte) (*IndexIterator, error) {
 }
 
 func (i *IndexReader) seekBucket(n int, tbuf []byte) (int64, int, error) {
-	_, err := i.file.ReadAt(tbuf, fileHeaderLen+int64(n*12))
+	_, err := i.file.ReadAt(tbuf, i.header.dataOffset()+int64(n*12))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -55,6 +57,21 @@ func (i *IndexReader) seekBucket(n int, tbuf []byte) (int64, int, error) {
 		nil
 }
 
+// readSlots reads nslots slots at offset
+func (i *IndexReader) readSlots(offset int64, nslots int) ([]slot, error) {
+	buf := make([]byte, nslots*12)
+	if _, err := i.file.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+
+	slots := make([]slot, nslots)
+	for n := range slots {
+		slots[n].cksum = csum32(binary.LittleEndian.Uint32(buf[n*12:]))
+		slots[n].lpos = int64(binary.LittleEndian.Uint64(buf[n*12+4:]))
+	}
+	return slots, nil
+}
+
 // --------------------------------------------------------------------
 
 // IndexIterator allows index readers to iterate over matching offsets
@@ -70,6 +87,7 @@ type IndexIterator struct {
 
 	err  error
 	tbuf []byte
+	obs  Observer
 }
 
 // Value returns the current log offset
@@ -80,8 +98,19 @@ func (i *IndexIterator) Error() error { return i.err }
 
 // Next advances to the next matching slot, returns true if successful
 func (i *IndexIterator) Next() bool {
+	ok, probes := i.next()
+	if i.obs != nil && probes != 0 {
+		i.obs.ObserveProbe(probes)
+	}
+	return ok
+}
+
+// next advances to the next matching slot, returns the number of probed slots
+func (i *IndexIterator) next() (bool, int) {
+	probes := 0
 	for i.err == nil && i.steps < i.nslots {
 		slot, err := i.readCurrent()
+		probes++
 
 		if err != nil {
 			i.err = err
@@ -100,10 +129,10 @@ func (i *IndexIterator) Next() bool {
 
 		if slot.cksum == i.cksum {
 			i.current = slot
-			return true
+			return true, probes
 		}
 	}
-	return false
+	return false, probes
 }
 
 func (i *IndexIterator) readCurrent() (s slot, err error) {
@@ -4,10 +4,18 @@ import (
 	"encoding/binary"
 	"io"
 	"os"
+	"time"
 )
 
-// WriteIndex iterates over log file and (over-)writes an index file
+// WriteIndex iterates over log file and (over-)writes an index file,
+// omitting entries which have expired
 func WriteIndex(indexFileName, logFileName string) error {
+	return WriteIndexAt(indexFileName, logFileName, time.Now())
+}
+
+// WriteIndexAt iterates over log file and (over-)writes an index file,
+// omitting entries which have expired as of now
+func WriteIndexAt(indexFileName, logFileName string, now time.Time) error {
 	reader, err := OpenLog(logFileName)
 	if err != nil {
 		return err
@@ -20,18 +28,22 @@ func WriteIndex(indexFileName, logFileName string) error {
 	}
 	defer dst.Close()
 
-	return writeIndex(reader, dst)
+	return writeIndex(reader, dst, now)
 }
 
 // writeIndex iterates over source log and writes an index
-func writeIndex(reader *LogReader, dst io.Writer) error {
+func writeIndex(reader *LogReader, dst io.Writer, now time.Time) error {
 
 	// Accumulate bucket information
-	iter := reader.iterator()
+	iter := reader.Iterator()
+	iter.skipVal = true
 	buckets := make([][]slot, numBuckets)
 	for iter.Next() {
 		entry := iter.Entry()
-		cksum := entry.Checksum()
+		if entry.Expired(now) {
+			continue
+		}
+		cksum := entry.checksum()
 		bucket := cksum.Bucket()
 
 		buckets[bucket] = append(buckets[bucket], slot{cksum, entry.Pos})
@@ -72,8 +84,9 @@ func writeIndex(reader *LogReader, dst io.Writer) error {
 // --------------------------------------------------------------------
 
 type indexWriter struct {
-	dst io.Writer
-	buf []byte // reusable buffer
+	dst  io.Writer
+	buf  []byte // reusable buffer
+	hlen int64  // header length
 }
 
 func newIndexWriter(dst io.Writer) *indexWriter {
@@ -85,18 +98,19 @@ func newIndexWriter(dst io.Writer) *indexWriter {
 
 // WriteHeader writes the file header
 func (w *indexWriter) WriteHeader(header *fileHeader) error {
-	_, err := header.WriteTo(w.dst)
+	n, err := header.WriteTo(w.dst)
+	w.hlen = n
 	return err
 }
 
 // WriteBuckets writes bucket index
 func (w *indexWriter) WriteBuckets(buckets [][]slot) error {
-	ipos := len(w.buf) + fileHeaderLen
+	ipos := int64(len(w.buf)) + w.hlen
 	for i, slots := range buckets {
 		nslots := len(slots) * 2
 		binary.LittleEndian.PutUint64(w.buf[i*12:], uint64(ipos))
 		binary.LittleEndian.PutUint32(w.buf[i*12+8:], uint32(nslots))
-		ipos += nslots * 12
+		ipos += int64(nslots) * 12
 	}
 
 	_, err := w.dst.Write(w.buf)
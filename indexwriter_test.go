@@ -3,6 +3,7 @@ package ccdb
 import (
 	"bytes"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -22,7 +23,7 @@ var _ = Describe("WriteIndex", func() {
 		defer reader.Close()
 
 		out := &bytes.Buffer{}
-		Expect(writeIndex(reader, out)).NotTo(HaveOccurred())
+		Expect(writeIndex(reader, out, time.Now())).NotTo(HaveOccurred())
 		Expect(out.Len()).To(Equal(4400))
 	})
 
@@ -0,0 +1,86 @@
+//go:build go1.23
+// +build go1.23
+
+package ccdb
+
+import (
+	"io"
+	"iter"
+)
+
+// Values returns a sequence of the values of a key, for use with range.
+// Iteration stops after the first error.
+func (db *DB) Values(key []byte) iter.Seq2[[]byte, error] {
+	return seqValues(func() (*Iterator, error) { return db.Get(key) })
+}
+
+// Sections returns a sequence of readable sections of the values of a key.
+// Iteration stops after the first error.
+func (db *DB) Sections(key []byte) iter.Seq2[*io.SectionReader, error] {
+	return seqSections(func() (*Iterator, error) { return db.Get(key) })
+}
+
+// Values returns a sequence of the values of a key from all databases.
+// Iteration stops after the first error.
+func (m *MultiDB) Values(key []byte) iter.Seq2[[]byte, error] {
+	return seqValues(func() (*Iterator, error) { return m.Get(key) })
+}
+
+// Sections returns a sequence of readable sections of the values of a key
+// from all databases. Iteration stops after the first error.
+func (m *MultiDB) Sections(key []byte) iter.Seq2[*io.SectionReader, error] {
+	return seqSections(func() (*Iterator, error) { return m.Get(key) })
+}
+
+// Entries returns a sequence of all log entries, for use with range.
+// Iteration stops after the first error.
+func (r *LogReader) Entries() iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		it := r.Iterator()
+		for it.Next() {
+			if !yield(*it.Entry(), nil) {
+				return
+			}
+		}
+		if err := it.Error(); err != nil {
+			yield(Entry{}, err)
+		}
+	}
+}
+
+func seqValues(get func() (*Iterator, error)) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		it, err := get()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for it.Next() {
+			val, err := it.Value()
+			if !yield(val, err) || err != nil {
+				return
+			}
+		}
+		if err := it.Error(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func seqSections(get func() (*Iterator, error)) iter.Seq2[*io.SectionReader, error] {
+	return func(yield func(*io.SectionReader, error) bool) {
+		it, err := get()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for it.Next() {
+			if !yield(it.Section(), nil) {
+				return
+			}
+		}
+		if err := it.Error(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+//go:build go1.23
+// +build go1.23
+
+package ccdb
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("range iterators", func() {
+	var dir, lname, iname string
+	var db *DB
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		lname, iname = filepath.Join(dir, "test.ccl"), filepath.Join(dir, "test.cci")
+
+		writer, err := CreateLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		for _, kv := range [][]string{{"foo", "v1"}, {"bar", "v2"}, {"foo", "v3"}} {
+			Expect(writer.Put([]byte(kv[0]), []byte(kv[1]))).To(Succeed())
+		}
+		Expect(writer.WriteIndex(iname)).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		db, err = Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should range over values", func() {
+		var vals []string
+		for val, err := range db.Values([]byte("foo")) {
+			Expect(err).NotTo(HaveOccurred())
+			vals = append(vals, string(val))
+		}
+		Expect(vals).To(Equal([]string{"v1", "v3"}))
+
+		for range db.Values([]byte("missing")) {
+			Fail("unexpected value")
+		}
+	})
+
+	It("should stop early", func() {
+		n := 0
+		for range db.Values([]byte("foo")) {
+			n++
+			break
+		}
+		Expect(n).To(Equal(1))
+	})
+
+	It("should range over sections", func() {
+		var vals []string
+		for section, err := range db.Sections([]byte("foo")) {
+			Expect(err).NotTo(HaveOccurred())
+			data, err := ioutil.ReadAll(section)
+			Expect(err).NotTo(HaveOccurred())
+			vals = append(vals, string(data))
+		}
+		Expect(vals).To(Equal([]string{"v1", "v3"}))
+	})
+
+	It("should range over multiple databases", func() {
+		multi := NewMultiDB([]*DB{db, db}, nil)
+
+		var vals []string
+		for val, err := range multi.Values([]byte("bar")) {
+			Expect(err).NotTo(HaveOccurred())
+			vals = append(vals, string(val))
+		}
+		Expect(vals).To(Equal([]string{"v2", "v2"}))
+	})
+
+	It("should range over log entries", func() {
+		reader, err := OpenLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		var keys []string
+		var offsets []int64
+		for ent, err := range reader.Entries() {
+			Expect(err).NotTo(HaveOccurred())
+			keys = append(keys, string(ent.Key))
+			offsets = append(offsets, ent.Pos)
+		}
+		Expect(keys).To(Equal([]string{"foo", "bar", "foo"}))
+		Expect(offsets).To(Equal([]int64{128, 135, 142}))
+	})
+
+	It("should yield errors", func() {
+		Expect(os.Truncate(lname, 141)).To(Succeed())
+
+		reader, err := OpenLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		var errs []error
+		for _, err := range reader.Entries() {
+			errs = append(errs, err)
+		}
+		Expect(errs).To(Equal([]error{nil, io.ErrUnexpectedEOF}))
+	})
+
+})
@@ -1,47 +1,144 @@
 package ccdb
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
 	"encoding/binary"
 	"io"
+	"time"
 )
 
+// ReadOptions can be used to configure readers
+type ReadOptions struct {
+	// Keys provides the keys of encrypted logs. Without keys, encrypted
+	// logs can still be opened and indexed, but values cannot be read.
+	Keys KeyProvider
+
+	// PublicKey verifies the signature of a database before it is opened,
+	// see Sign. Only applies to DBs. Default: signatures are not verified
+	PublicKey ed25519.PublicKey
+
+	// Observer receives lookup and read events. Default: none
+	Observer Observer
+}
+
 // LogReader can lookup key/value pairs by offset
 type LogReader struct {
 	*fileReader
+	aead cipher.AEAD
+	src  io.ReaderAt // file, optionally observed
+	obs  Observer
 }
 
 // OpenLog opens a log file for reading. Example:
 //     ccdb.OpenLog("/path/to/my/db.ccl")
 func OpenLog(fname string) (*LogReader, error) {
+	return OpenLogWithOptions(fname, nil)
+}
+
+// OpenLogWithOptions opens a log file for reading using custom options
+func OpenLogWithOptions(fname string, opt *ReadOptions) (*LogReader, error) {
 	reader, err := openFileReader(fname)
 	if err != nil {
 		return nil, err
 	}
 
-	return &LogReader{reader}, nil
+	r := &LogReader{fileReader: reader, src: reader.file}
+	if opt != nil && opt.Keys != nil && reader.header.encrypted() {
+		if r.aead, err = newAEAD(opt.Keys, reader.header); err != nil {
+			reader.Close()
+			return nil, err
+		}
+	}
+	if opt != nil && opt.Observer != nil {
+		r.src = observedReaderAt{ReaderAt: reader.file, obs: opt.Observer}
+		r.obs = opt.Observer
+	}
+	return r, nil
 }
 
 // GetReader returns a key and a value reader
 func (r *LogReader) GetReader(offset int64) ([]byte, *io.SectionReader, error) {
-	if offset < fileHeaderLen || offset >= r.header.pos {
+	ent, sr, err := r.entryAt(offset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if sr, err = r.valueSection(ent, sr); err != nil {
+		return nil, nil, err
+	}
+	return ent.Key, sr, nil
+}
+
+// GetEntry returns the entry at an offset, including timestamp and TTL
+func (r *LogReader) GetEntry(offset int64) (*Entry, error) {
+	ent, sr, err := r.entryAt(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if sr, err = r.valueSection(ent, sr); err != nil {
+		return nil, err
+	}
+	if ent.Val, err = readSection(sr); err != nil {
+		return nil, err
+	}
+	return ent, nil
+}
+
+// entryAt returns the entry at an offset, without the value,
+// and a reader for the stored value data
+func (r *LogReader) entryAt(offset int64) (*Entry, *io.SectionReader, error) {
+	if offset < r.header.dataOffset() || offset >= r.header.pos {
 		return nil, nil, errInvalidOffset
 	}
 
-	buf := make([]byte, 20)
+	buf := make([]byte, 4*binary.MaxVarintLen64)
 
-	if n, err := r.file.ReadAt(buf, offset); err != nil && (err != io.EOF || n < 4) {
+	if n, err := r.src.ReadAt(buf, offset); err != nil && (err != io.EOF || n < 4) {
 		return nil, nil, err
 	}
 
 	klen, n := binary.Uvarint(buf)
 	vlen, m := binary.Uvarint(buf[n:])
+	n += m
 
-	min := offset + int64(n+m)
-	key := make([]byte, klen)
-	if _, err := r.file.ReadAt(key, min); err != nil {
+	ent := &Entry{Pos: offset}
+	if r.header.timestamps() {
+		ts, m := binary.Uvarint(buf[n:])
+		n += m
+		ttl, m := binary.Uvarint(buf[n:])
+		n += m
+		ent.Timestamp, ent.TTL = decodeTimestamp(ts, ttl)
+	}
+
+	min := offset + int64(n)
+	ent.Key = make([]byte, klen)
+	if _, err := r.src.ReadAt(ent.Key, min); err != nil {
 		return nil, nil, err
 	}
-	return key, io.NewSectionReader(r.file, min+int64(klen), int64(vlen)), nil
+	return ent, io.NewSectionReader(r.src, min+int64(klen), int64(vlen)), nil
+}
+
+// valueSection returns a reader for the plain value of an entry,
+// encrypted values are decrypted into memory
+func (r *LogReader) valueSection(ent *Entry, sr *io.SectionReader) (*io.SectionReader, error) {
+	if !r.header.encrypted() {
+		return sr, nil
+	} else if r.aead == nil {
+		return nil, ErrMissingKey
+	}
+
+	data, err := readSection(sr)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = openValue(r.aead, r.header.id, ent.Pos, ent.Key, data); err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))), nil
 }
 
 // Get returns a key/value pair at an offset
@@ -55,75 +152,170 @@ func (r *LogReader) Get(offset int64) ([]byte, []byte, error) {
 	return key, val, err
 }
 
-func (r *LogReader) iterator() *logIterator {
-	return &logIterator{
-		src:  r.file,
-		pos:  fileHeaderLen,
-		tbuf: make([]byte, 1),
-	}
+// Iterator returns an iterator over all log entries
+func (r *LogReader) Iterator() *LogIterator {
+	iter := newLogIterator(r.src, r.header, r.header.pos)
+	iter.aead = r.aead
+	return iter
 }
 
 // --------------------------------------------------------------------
 
-type logIterator struct {
-	src io.Reader
+// LogIterator allows to iterate over log entries
+type LogIterator struct {
+	src *bufio.Reader
 	err error
 
-	pos int64
-	cur logEntry
+	pos, end int64
+	cur      Entry
+	vlen     int64 // plain value length of the current entry
+	skipVal  bool  // skip value data
 
-	tbuf []byte
+	id         uint32      // file ID
+	timestamps bool        // entries carry timestamps
+	encrypted  bool        // values are encrypted
+	aead       cipher.AEAD // decrypts values
 }
 
-func (i *logIterator) ReadByte() (byte, error) {
-	_, err := i.src.Read(i.tbuf)
-	if err == nil {
-		i.pos++
-	}
-	return i.tbuf[0], err
+// newLogIterator iterates over the entries of a file with the given header, up to end
+func newLogIterator(src io.ReaderAt, header *fileHeader, end int64) *LogIterator {
+	return newLogIteratorAt(src, header, header.dataOffset(), end)
 }
 
-func (i *logIterator) Read(p []byte) (int, error) {
-	n, err := i.src.Read(p)
-	if err == nil {
-		i.pos += int64(n)
+// newLogIteratorAt iterates over the entries within [pos, end),
+// pos must be an entry boundary
+func newLogIteratorAt(src io.ReaderAt, header *fileHeader, pos, end int64) *LogIterator {
+	return &LogIterator{
+		src:        bufio.NewReader(io.NewSectionReader(src, pos, end-pos)),
+		pos:        pos,
+		end:        end,
+		id:         header.id,
+		timestamps: header.timestamps(),
+		encrypted:  header.encrypted(),
 	}
-	return n, err
 }
 
-func (i *logIterator) Next() bool {
+// Next advances to the next entry, returns true if successful
+func (i *LogIterator) Next() bool {
 	if i.err != nil {
 		return false
 	}
 
-	i.cur.Pos = i.pos
-	kn, err := binary.ReadUvarint(i)
+	pos := i.pos
+	kn, err := binary.ReadUvarint(iterByteReader{i})
 	if err != nil {
 		i.err = err
 		return false
 	}
 
-	vn, err := binary.ReadUvarint(i)
+	vn, err := binary.ReadUvarint(iterByteReader{i})
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
 		i.err = err
 		return false
 	}
 
-	i.cur.Key = make([]byte, int(kn))
-	if _, i.err = i.Read(i.cur.Key); i.err != nil {
+	var ts, ttl uint64
+	if i.timestamps {
+		if ts, err = binary.ReadUvarint(iterByteReader{i}); err == nil {
+			ttl, err = binary.ReadUvarint(iterByteReader{i})
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			i.err = err
+			return false
+		}
+	}
+
+	if kn == 0 || vn == 0 || (i.encrypted && vn <= gcmOverhead) {
+		i.err = errInvalidEntry
+		return false
+	} else if rem := uint64(i.end - i.pos); kn > rem || vn > rem-kn {
+		i.err = io.ErrUnexpectedEOF
+		return false
+	}
+
+	key := make([]byte, int(kn))
+	if _, i.err = io.ReadFull(i.src, key); i.err != nil {
 		return false
 	}
-	i.cur.Val = make([]byte, int(vn))
-	if _, i.err = i.Read(i.cur.Val); i.err != nil {
+
+	var val []byte
+	if i.skipVal {
+		_, i.err = i.src.Discard(int(vn))
+	} else if i.encrypted && i.aead == nil {
+		i.err = ErrMissingKey
+	} else {
+		val = make([]byte, int(vn))
+		_, i.err = io.ReadFull(i.src, val)
+	}
+	if i.err != nil {
 		return false
 	}
+	if val != nil && i.encrypted {
+		if val, i.err = openValue(i.aead, i.id, pos, key, val); i.err != nil {
+			return false
+		}
+	}
+
+	i.pos += int64(kn + vn)
+	i.cur = Entry{Pos: pos, Key: key, Val: val}
+	if i.timestamps {
+		i.cur.Timestamp, i.cur.TTL = decodeTimestamp(ts, ttl)
+	}
+	i.vlen = int64(vn)
+	if i.encrypted {
+		i.vlen -= gcmOverhead
+	}
 	return true
 }
 
-func (i *logIterator) Entry() *logEntry { return &i.cur }
-func (i *logIterator) Error() error {
+// Entry returns the current entry
+func (i *LogIterator) Entry() *Entry { return &i.cur }
+
+// Error returns an error if one occurred during iteration
+func (i *LogIterator) Error() error {
 	if i.err == io.EOF {
 		return nil
 	}
 	return i.err
 }
+
+// iterByteReader reads bytes and advances the iterator position
+type iterByteReader struct{ *LogIterator }
+
+func (r iterByteReader) ReadByte() (byte, error) {
+	b, err := r.src.ReadByte()
+	if err == nil {
+		r.pos++
+	}
+	return b, err
+}
+
+// --------------------------------------------------------------------
+
+// encodeTimestamp encodes a timestamp and a TTL in seconds,
+// a zero timestamp or TTL is encoded as 0
+func encodeTimestamp(ts time.Time, ttl time.Duration) (uint64, uint64) {
+	var sec uint64
+	if !ts.IsZero() {
+		sec = uint64(ts.Unix())
+	}
+	secs := ttl / time.Second
+	if ttl%time.Second != 0 {
+		secs++
+	}
+	return sec, uint64(secs)
+}
+
+func decodeTimestamp(sec, ttl uint64) (time.Time, time.Duration) {
+	var ts time.Time
+	if sec != 0 {
+		ts = time.Unix(int64(sec), 0)
+	}
+	return ts, time.Duration(ttl) * time.Second
+}
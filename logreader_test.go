@@ -85,8 +85,8 @@ var _ = Describe("LogReader", func() {
 
 })
 
-var _ = Describe("logIterator", func() {
-	var subject *logIterator
+var _ = Describe("LogIterator", func() {
+	var subject *LogIterator
 	var reader *LogReader
 	var dir string
 
@@ -99,7 +99,7 @@ var _ = Describe("logIterator", func() {
 		reader, err = OpenLog(fname)
 		Expect(err).NotTo(HaveOccurred())
 
-		subject = reader.iterator()
+		subject = reader.Iterator()
 	})
 
 	AfterEach(func() {
@@ -108,14 +108,14 @@ var _ = Describe("logIterator", func() {
 	})
 
 	It("should iterate", func() {
-		var acc []logEntry
+		var acc []Entry
 		for subject.Next() {
 			acc = append(acc, *subject.Entry())
 		}
 		Expect(subject.Error()).NotTo(HaveOccurred())
 		Expect(acc).To(HaveLen(1390))
 
-		Expect(acc[585]).To(Equal(logEntry{Pos: 12413, Key: []byte("key.0306"), Val: []byte("val.0306.00")}))
-		Expect(acc[1025]).To(Equal(logEntry{Pos: 21653, Key: []byte("key.0422"), Val: []byte("val.0422.03")}))
+		Expect(acc[585]).To(Equal(Entry{Pos: 12413, Key: []byte("key.0306"), Val: []byte("val.0306.00")}))
+		Expect(acc[1025]).To(Equal(Entry{Pos: 21653, Key: []byte("key.0422"), Val: []byte("val.0422.03")}))
 	})
 })
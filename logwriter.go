@@ -2,52 +2,256 @@ package ccdb
 
 import (
 	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"sync"
+	"time"
 )
 
-// LogWriter instances append to log files.
-// WARNING: log writers are not thread-safe,
-// use a single writer thread or protect concurrent writes with a mutex.
+// SyncPolicy determines when log data is committed to stable storage.
+// The zero value only commits on explicit calls to Flush or Close.
+type SyncPolicy struct {
+	// EveryN commits after every N entries. A value of 1 commits on every Put.
+	EveryN int
+
+	// Interval commits periodically, in the background.
+	Interval time.Duration
+}
+
+var (
+	// SyncNever only commits data on Flush or Close
+	SyncNever = SyncPolicy{}
+	// SyncAlways commits every entry before Put returns
+	SyncAlways = SyncPolicy{EveryN: 1}
+)
+
+// LogOptions can be used to configure log writers
+type LogOptions struct {
+	// Sync determines the durability policy. Concurrent callers
+	// waiting for a commit are grouped and share a single fsync().
+	Sync SyncPolicy
+
+	// FileID assigns a custom identifier to new log files. When appending,
+	// the identifier must match the existing file. Default: random
+	FileID uint32
+
+	// Rand is the source of random file IDs. Default: crypto/rand.Reader
+	Rand io.Reader
+
+	// Perm sets the permissions of new log files (before umask). Default: 0666
+	Perm os.FileMode
+
+	// BufferSize sets the size of the write buffer. Default: 1MiB
+	BufferSize int
+
+	// MaxKeySize limits the size of keys. Default: unlimited
+	MaxKeySize int
+
+	// MaxValueSize limits the size of values. Default: unlimited
+	MaxValueSize int64
+
+	// Exclusive fails log creation if the file already exists.
+	Exclusive bool
+
+	// Metadata is stored in the header of new log files and carried over
+	// into their indexes. Encoded metadata of up to 104 bytes fits into the
+	// header, larger metadata of up to 64KiB is stored in an extended header
+	// section, which requires readers supporting version 1.2. Metadata
+	// cannot be changed when appending to existing logs.
+	Metadata map[string]string
+
+	// Timestamps creates logs which store a timestamp and an optional TTL
+	// with every entry. Such logs require readers supporting version 1.1.
+	// When appending, the format of the existing file is retained.
+	Timestamps bool
+
+	// Clock returns the time for entry timestamps. Default: time.Now
+	Clock func() time.Time
+
+	// Keys enables AES-GCM encryption of values in new log files, using
+	// the key identified by KeyID. Entry keys remain unencrypted, as they
+	// are required for indexing. Appending to encrypted files requires
+	// the key referenced by the file, appending to unencrypted files with
+	// Keys fails with ErrNotEncrypted.
+	Keys KeyProvider
+
+	// KeyID identifies the encryption key for new log files.
+	KeyID uint32
+
+	// Observer receives put and flush events. Default: none
+	Observer Observer
+}
+
+func (o *LogOptions) norm() (*LogOptions, error) {
+	var opt LogOptions
+	if o != nil {
+		opt = *o
+	}
+
+	if opt.BufferSize < 0 {
+		return nil, ErrInvalidBufferSize
+	} else if opt.BufferSize == 0 {
+		opt.BufferSize = 1024 * 1024
+	}
+	if opt.MaxKeySize < 0 || opt.MaxValueSize < 0 {
+		return nil, ErrInvalidSizeLimit
+	}
+	if _, err := encodeMetadata(opt.Metadata); err != nil {
+		return nil, err
+	}
+	if opt.Rand == nil {
+		opt.Rand = rand.Reader
+	}
+	if opt.Perm == 0 {
+		opt.Perm = 0666
+	}
+	if opt.Clock == nil {
+		opt.Clock = time.Now
+	}
+	return &opt, nil
+}
+
+// --------------------------------------------------------------------
+
+// LogWriter instances append to log files. Writers are safe for
+// concurrent use: entries are appended atomically, in the order in which
+// callers acquire the writer, and callers waiting for a commit share a
+// single fsync(). Close must not be called concurrently with other methods.
 type LogWriter struct {
 	header *fileHeader
 	file   *os.File
 	buffer *bufio.Writer
+	policy SyncPolicy
+
+	maxKeySize   int
+	maxValueSize int64
+	clock        func() time.Time
+	aead         cipher.AEAD // encrypts values
+	obs          Observer
+	flushed      int64 // position of the last observed flush
 
 	mutex     sync.Mutex // write mutex
 	seekToPos bool       // out-of-position
+	failed    error      // permanent failure, rejects further writes
+
+	commits  *sync.Cond    // signals completed commits
+	syncing  bool          // commit in progress
+	synced   int64         // last committed position
+	unsynced int           // number of uncommitted entries
+	waiters  []syncWaiter  // pending async notifications
+	closing  chan struct{} // stops background commits
+	closed   chan struct{}
 
 	tbuf []byte // temporary buffers
 }
 
-func newLogWriter(header *fileHeader, file *os.File) *LogWriter {
-	return &LogWriter{
+type syncWaiter struct {
+	pos  int64
+	done chan error
+
+	// async puts are observed once committed
+	size int64
+	t    time.Time
+}
+
+func newLogWriter(header *fileHeader, file *os.File, aead cipher.AEAD, opt *LogOptions) *LogWriter {
+	w := &LogWriter{
 		header: header,
 		file:   file,
-		buffer: bufio.NewWriterSize(file, 1024*1024),
-		tbuf:   make([]byte, binary.MaxVarintLen64),
+		aead:   aead,
+		buffer: bufio.NewWriterSize(file, opt.BufferSize),
+		policy: opt.Sync,
+		tbuf:   make([]byte, 4*binary.MaxVarintLen64),
+
+		maxKeySize:   opt.MaxKeySize,
+		maxValueSize: opt.MaxValueSize,
+		clock:        opt.Clock,
+		obs:          opt.Observer,
+		flushed:      header.pos,
 	}
+	w.commits = sync.NewCond(&w.mutex)
+
+	if w.policy.Interval > 0 {
+		w.closing = make(chan struct{})
+		w.closed = make(chan struct{})
+		go w.loop()
+	}
+	return w
 }
 
 // CreateLog creates a new log file
 func CreateLog(fname string) (*LogWriter, error) {
-	file, err := os.Create(fname)
+	return CreateLogWithOptions(fname, nil)
+}
+
+// CreateLogWithOptions creates a new log file using custom options
+func CreateLogWithOptions(fname string, opt *LogOptions) (*LogWriter, error) {
+	opt, err := opt.norm()
+	if err != nil {
+		return nil, err
+	}
+
+	id := opt.FileID
+	if id == 0 {
+		if id, err = randomFileID(opt.Rand); err != nil {
+			return nil, err
+		}
+	}
+
+	header := newFileHeader(id)
+	meta, _ := encodeMetadata(opt.Metadata)
+	header.setMetadata(meta)
+	if opt.Timestamps {
+		header.setFlags(flagTimestamps)
+	}
+
+	var aead cipher.AEAD
+	if opt.Keys != nil {
+		header.setFlags(flagEncrypted)
+		header.keyID = opt.KeyID
+		if aead, err = newAEAD(opt.Keys, header); err != nil {
+			return nil, err
+		}
+	}
+
+	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if opt.Exclusive {
+		flag |= os.O_EXCL
+	}
+
+	file, err := os.OpenFile(fname, flag, opt.Perm)
 	if err != nil {
 		return nil, err
 	}
 
-	header := newFileHeader()
 	if _, err = header.WriteTo(file); err != nil {
 		file.Close()
 		return nil, err
 	}
 
-	return newLogWriter(header, file), nil
+	return newLogWriter(header, file, aead, opt), nil
 }
 
 // AppendLog opens an existing log file, to append new data
 func AppendLog(fname string) (*LogWriter, error) {
+	return AppendLogWithOptions(fname, nil)
+}
+
+// AppendLogWithOptions opens an existing log file using custom options
+func AppendLogWithOptions(fname string, opt *LogOptions) (*LogWriter, error) {
+	opt, err := opt.norm()
+	if err != nil {
+		return nil, err
+	}
+	if opt.Metadata != nil {
+		return nil, ErrMetadataImmutable
+	}
+
 	file, err := os.OpenFile(fname, os.O_RDWR, 0664)
 	if err != nil {
 		return nil, err
@@ -59,12 +263,28 @@ func AppendLog(fname string) (*LogWriter, error) {
 		return nil, err
 	}
 
+	if opt.FileID != 0 && opt.FileID != header.id {
+		file.Close()
+		return nil, ErrFileIDMismatch
+	}
+
+	var aead cipher.AEAD
+	if header.encrypted() {
+		if aead, err = newAEAD(opt.Keys, header); err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else if opt.Keys != nil {
+		file.Close()
+		return nil, ErrNotEncrypted
+	}
+
 	if _, err = file.Seek(header.pos, os.SEEK_SET); err != nil {
 		file.Close()
 		return nil, err
 	}
 
-	return newLogWriter(header, file), nil
+	return newLogWriter(header, file, aead, opt), nil
 }
 
 // Flush flushes all buffers, rewrites header and issues an fsync()
@@ -72,43 +292,220 @@ func (w *LogWriter) Flush() error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	err := w.buffer.Flush()
-	if err != nil {
+	return w.commit(w.header.pos)
+}
+
+// Close flushes all buffers and closes the underlying file
+func (w *LogWriter) Close() error {
+	if w.closing != nil {
+		close(w.closing)
+		<-w.closed
+		w.closing = nil
+	}
+
+	err := w.Flush()
+	if e := w.file.Close(); e != nil {
+		err = e
+	}
+	return err
+}
+
+// Put inserts a new key/value pair to the log. Depending on the sync policy,
+// it may block until the entry is committed.
+func (w *LogWriter) Put(key, val []byte) error {
+	return w.PutWithTTL(key, val, 0)
+}
+
+// PutWithTTL inserts a new key/value pair, which expires after ttl.
+// TTLs are stored with second precision and require a log with
+// timestamps. A zero TTL never expires.
+func (w *LogWriter) PutWithTTL(key, val []byte, ttl time.Duration) error {
+	if err := w.validate(key, int64(len(val))); err != nil {
+		return err
+	}
+	if err := w.validateTTL(ttl); err != nil {
 		return err
 	}
 
-	w.seekToPos = true
+	t := time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
 
-	if _, err = w.file.Seek(0, os.SEEK_SET); err != nil {
+	start := w.header.pos
+	if err := w.writeEntry(key, val, time.Time{}, ttl); err != nil {
 		return err
 	}
-	if _, err = w.header.WriteTo(w.file); err != nil {
+	return w.finishPut(1, w.header.pos-start, t)
+}
+
+// PutAsync inserts a new key/value pair to the log and returns a channel
+// which receives nil once the entry is committed or an error if the write
+// or commit failed. Commits are triggered according to the sync policy
+// or by explicit calls to Flush.
+func (w *LogWriter) PutAsync(key, val []byte) <-chan error {
+	done := make(chan error, 1)
+	if err := w.validate(key, int64(len(val))); err != nil {
+		done <- err
+		return done
+	}
+
+	t := time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	start := w.header.pos
+	if err := w.writeEntry(key, val, time.Time{}, 0); err != nil {
+		done <- err
+		return done
+	}
+	w.waiters = append(w.waiters, syncWaiter{pos: w.header.pos, done: done, size: w.header.pos - start, t: t})
+	if w.scheduleCommit() {
+		go w.Flush()
+	}
+	return done
+}
+
+// PutReader inserts a new key/value pair to the log, streaming exactly size
+// bytes of the value from r. The entry is discarded if r returns an error
+// or fewer than size bytes.
+func (w *LogWriter) PutReader(key []byte, r io.Reader, size int64) error {
+	if err := w.validate(key, size); err != nil {
 		return err
 	}
 
-	return w.file.Sync()
+	// encrypted values must be sealed as a whole
+	if w.aead != nil {
+		val := make([]byte, size)
+		if _, err := io.ReadFull(r, val); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errValueSize
+		} else if err != nil {
+			return err
+		}
+		return w.Put(key, val)
+	}
+
+	t := time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	start, err := w.begin()
+	if err != nil {
+		return err
+	}
+
+	if err := w.writeEntryHeader(key, size, time.Time{}, 0); err != nil {
+		return w.discard(start, w.header.pos, err)
+	}
+
+	n, err := io.CopyN(w.buffer, r, size)
+	w.header.pos += n
+	if err == io.EOF {
+		err = errValueSize
+	}
+	if err != nil {
+		return w.discard(start, w.header.pos, err)
+	}
+	return w.finishPut(1, w.header.pos-start, t)
 }
 
-// Close flushes all buffers and closes the underlying file
-func (w *LogWriter) Close() error {
-	err := w.Flush()
-	if e := w.file.Close(); e != nil {
-		err = e
+// PutWriter inserts a new key/value pair to the log and returns a writer
+// for a value of exactly size bytes. The entry is discarded if fewer
+// than size bytes were written.
+//
+// The writer lock is held until the returned writer is closed: Put, Flush,
+// Close and all other writer methods block in the meantime, so callers
+// must not wait for other users of the log before closing the value
+// writer. Values of encrypted logs are buffered in memory and written on
+// Close, without holding the lock.
+func (w *LogWriter) PutWriter(key []byte, size int64) (io.WriteCloser, error) {
+	if err := w.validate(key, size); err != nil {
+		return nil, err
 	}
-	return err
+	if w.aead != nil {
+		return &bufferedValueWriter{w: w, key: key, remaining: size}, nil
+	}
+
+	t := time.Now()
+	w.mutex.Lock()
+
+	start, err := w.begin()
+	if err != nil {
+		w.mutex.Unlock()
+		return nil, err
+	}
+
+	if err := w.writeEntryHeader(key, size, time.Time{}, 0); err != nil {
+		err = w.discard(start, w.header.pos, err)
+		w.mutex.Unlock()
+		return nil, err
+	}
+	return &valueWriter{w: w, start: start, remaining: size, t: t}, nil
 }
 
-// Put inserts a new key/value pair to the log
-func (w *LogWriter) Put(key, val []byte) error {
+// Size returns the current size of the log, including buffered data
+func (w *LogWriter) Size() int64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.header.pos
+}
+
+// WriteIndex writes an index for the current log into the target file path
+func (w *LogWriter) WriteIndex(fname string) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return WriteIndex(fname, w.file.Name())
+}
+
+// validate validates a key and the size of a value
+func (w *LogWriter) validate(key []byte, vlen int64) error {
 	if len(key) == 0 {
 		return errBlankKey
-	} else if len(val) == 0 {
+	} else if vlen <= 0 {
 		return errBlankValue
+	} else if w.maxKeySize != 0 && len(key) > w.maxKeySize {
+		return ErrKeyTooLarge
+	} else if w.maxValueSize != 0 && vlen > w.maxValueSize {
+		return ErrValueTooLarge
+	}
+	return nil
+}
+
+// maxTTL is the largest TTL which can be stored in whole seconds
+const maxTTL = math.MaxInt64 / time.Second * time.Second
+
+// validateTTL validates a TTL
+func (w *LogWriter) validateTTL(ttl time.Duration) error {
+	if ttl < 0 || ttl > maxTTL {
+		return ErrInvalidTTL
+	} else if ttl > 0 && !w.header.timestamps() {
+		return ErrNoTimestamps
 	}
+	return nil
+}
 
-	w.mutex.Lock()
-	defer w.mutex.Unlock()
+// writeEntry writes a key/value pair to the buffer, must be called with the mutex held
+func (w *LogWriter) writeEntry(key, val []byte, ts time.Time, ttl time.Duration) error {
+	if w.failed != nil {
+		return w.failed
+	}
+	if w.aead != nil {
+		var err error
+		if val, err = sealValue(w.aead, w.header.id, w.header.pos, key, val); err != nil {
+			return err
+		}
+	}
+	if err := w.writeEntryHeader(key, int64(len(val)), ts, ttl); err != nil {
+		return err
+	}
+	return w.write(val)
+}
 
+// writeEntryHeader writes the entry lengths, the timestamp and the key to
+// the buffer. Timestamps are only written to logs with timestamps, a zero
+// ts is replaced by the current time. Must be called with the mutex held.
+func (w *LogWriter) writeEntryHeader(key []byte, vlen int64, ts time.Time, ttl time.Duration) error {
 	if w.seekToPos {
 		if _, err := w.file.Seek(w.header.pos, os.SEEK_SET); err != nil {
 			return err
@@ -117,36 +514,343 @@ func (w *LogWriter) Put(key, val []byte) error {
 	}
 
 	n := binary.PutUvarint(w.tbuf, uint64(len(key)))
-	if _, err := w.buffer.Write(w.tbuf[:n]); err != nil {
+	n += binary.PutUvarint(w.tbuf[n:], uint64(vlen))
+	if w.header.timestamps() {
+		if ts.IsZero() {
+			ts = w.clock()
+		}
+		sec, ttl := encodeTimestamp(ts, ttl)
+		n += binary.PutUvarint(w.tbuf[n:], sec)
+		n += binary.PutUvarint(w.tbuf[n:], ttl)
+	}
+	if err := w.write(w.tbuf[:n]); err != nil {
 		return err
 	}
+	return w.write(key)
+}
+
+// writeRaw copies n bytes of pre-encoded entries from r to the
+// buffer, must be called with the mutex held
+func (w *LogWriter) writeRaw(r io.Reader, n int64) error {
+	if w.seekToPos {
+		if _, err := w.file.Seek(w.header.pos, os.SEEK_SET); err != nil {
+			return err
+		}
+		w.seekToPos = false
+	}
+
+	m, err := io.CopyN(w.buffer, r, n)
+	w.header.pos += m
+	return err
+}
+
+// write writes raw data to the buffer, must be called with the mutex held
+func (w *LogWriter) write(p []byte) error {
+	n, err := w.buffer.Write(p)
 	w.header.pos += int64(n)
+	return err
+}
+
+// begin flushes the buffer and returns the current position, which
+// can be used to roll back, must be called with the mutex held
+func (w *LogWriter) begin() (int64, error) {
+	if w.failed != nil {
+		return 0, w.failed
+	}
+	if err := w.buffer.Flush(); err != nil {
+		return 0, err
+	}
+	return w.header.pos, nil
+}
+
+// finishPut schedules a commit and reports size bytes of written
+// entries, must be called with the mutex held
+func (w *LogWriter) finishPut(entries int, size int64, t time.Time) error {
+	var err error
+	if w.scheduleCommit() {
+		err = w.commit(w.header.pos)
+	}
+	if err == nil && w.obs != nil {
+		w.obs.ObservePut(entries, size, time.Since(t))
+	}
+	return err
+}
+
+// scheduleCommit registers a written entry and returns true
+// if a commit is due, must be called with the mutex held
+func (w *LogWriter) scheduleCommit() bool {
+	w.unsynced++
+	return w.policy.EveryN > 0 && w.unsynced >= w.policy.EveryN
+}
+
+// commit ensures that all data up to pos is flushed and synced. Concurrent
+// callers are grouped: while one of them is waiting for the fsync(), others
+// continue to append and are covered by the next commit.
+// Must be called with the mutex held.
+func (w *LogWriter) commit(pos int64) error {
+	if w.synced >= pos {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	w.waiters = append(w.waiters, syncWaiter{pos: pos, done: done})
+	for len(done) == 0 {
+		if w.syncing {
+			w.commits.Wait()
+		} else {
+			w.sync()
+		}
+	}
+	return <-done
+}
+
+// sync flushes the buffer, issues an fsync(), rewrites the header, issues
+// another fsync() and notifies waiters. The header is only advanced once
+// the data it covers is synced, so entries of a failed commit are never
+// exposed to readers, even if other callers appended in the meantime. If
+// only the second fsync() fails, the entries are durable, but the header
+// may or may not reference them. Must be called with the mutex held.
+func (w *LogWriter) sync() {
+	t := time.Now()
+	if err := w.buffer.Flush(); err != nil {
+		w.notify(w.header.pos, err)
+		return
+	}
+
+	target := w.header.pos
+	w.syncing = true
+	w.unsynced = 0
+
+	err := w.syncFile()
+	if err == nil {
+		err = w.flushHeader(target)
+	}
+	if err == nil {
+		err = w.syncFile()
+	}
 
-	n = binary.PutUvarint(w.tbuf, uint64(len(val)))
-	if _, err := w.buffer.Write(w.tbuf[:n]); err != nil {
+	if err == nil {
+		if w.obs != nil {
+			w.obs.ObserveFlush(target-w.flushed, time.Since(t))
+			w.flushed = target
+		}
+		w.synced = target
+	}
+	w.syncing = false
+	w.notify(target, err)
+	w.commits.Broadcast()
+}
+
+// syncFile issues an fsync() without holding the mutex, so other callers
+// can continue to append, must be called with the mutex held
+func (w *LogWriter) syncFile() error {
+	w.mutex.Unlock()
+	defer w.mutex.Lock()
+
+	return w.file.Sync()
+}
+
+// flushHeader flushes the buffer and rewrites the header, storing pos as
+// the end of the data, must be called with the mutex held
+func (w *LogWriter) flushHeader(pos int64) error {
+	if err := w.buffer.Flush(); err != nil {
 		return err
 	}
-	w.header.pos += int64(n)
 
-	n, err := w.buffer.Write(key)
-	if err != nil {
+	w.seekToPos = true
+
+	if _, err := w.file.Seek(0, os.SEEK_SET); err != nil {
 		return err
 	}
-	w.header.pos += int64(n)
 
-	n, err = w.buffer.Write(val)
-	if err != nil {
+	header := *w.header
+	header.pos = pos
+	_, err := header.WriteTo(w.file)
+	return err
+}
+
+// discard discards the data within [start, end) after a failed write or
+// commit and returns err, along with rollback failures. If other callers
+// appended beyond end in the meantime, their entries cannot be discarded
+// and the writer fails instead. Must be called with the mutex held.
+func (w *LogWriter) discard(start, end int64, err error) error {
+	if w.failed != nil {
 		return err
 	}
-	w.header.pos += int64(n)
+	for w.syncing {
+		w.commits.Wait()
+	}
 
-	return nil
+	if w.header.pos != end {
+		w.failed = errWriterFailed
+		return err
+	}
+	if rerr := w.rollback(start); rerr != nil {
+		w.failed = errWriterFailed
+		return fmt.Errorf("%v (rollback failed: %v)", err, rerr)
+	}
+	return err
 }
 
-// WriteIndex writes an index for the current log into the target file path
-func (w *LogWriter) WriteIndex(fname string) error {
-	if err := w.Flush(); err != nil {
+// rollback discards all uncommitted data beyond pos,
+// must be called with the mutex held
+func (w *LogWriter) rollback(pos int64) error {
+	for w.syncing {
+		w.commits.Wait()
+	}
+	if w.synced > pos {
+		w.synced = pos
+	}
+	if w.flushed > pos {
+		w.flushed = pos
+	}
+
+	w.buffer.Reset(w.file)
+	w.header.pos = pos
+	w.unsynced = 0
+
+	n := 0
+	for _, wt := range w.waiters {
+		if wt.pos > pos {
+			wt.done <- errDiscarded
+		} else {
+			w.waiters[n] = wt
+			n++
+		}
+	}
+	w.waiters = w.waiters[:n]
+	w.commits.Broadcast()
+
+	if err := w.file.Truncate(pos); err != nil {
 		return err
 	}
-	return WriteIndex(fname, w.file.Name())
+	if err := w.flushHeader(pos); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// notify notifies async waiters up to pos, must be called with the mutex held
+func (w *LogWriter) notify(pos int64, err error) {
+	n := 0
+	for _, wt := range w.waiters {
+		if wt.pos <= pos {
+			if err == nil && wt.size != 0 && w.obs != nil {
+				w.obs.ObservePut(1, wt.size, time.Since(wt.t))
+			}
+			wt.done <- err
+		} else {
+			w.waiters[n] = wt
+			n++
+		}
+	}
+	w.waiters = w.waiters[:n]
+}
+
+func (w *LogWriter) loop() {
+	defer close(w.closed)
+
+	ticker := time.NewTicker(w.policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.closing:
+			return
+		case <-ticker.C:
+			w.mutex.Lock()
+			if w.unsynced != 0 && !w.syncing {
+				w.sync()
+			}
+			w.mutex.Unlock()
+		}
+	}
+}
+
+// --------------------------------------------------------------------
+
+type valueWriter struct {
+	w         *LogWriter
+	start     int64
+	remaining int64
+	t         time.Time // start of the put
+
+	err    error
+	closed bool
+}
+
+// Write writes value data
+func (v *valueWriter) Write(p []byte) (int, error) {
+	if v.closed {
+		return 0, errClosed
+	} else if v.err != nil {
+		return 0, v.err
+	} else if int64(len(p)) > v.remaining {
+		v.err = errValueSize
+		return 0, v.err
+	}
+
+	n, err := v.w.buffer.Write(p)
+	v.w.header.pos += int64(n)
+	v.remaining -= int64(n)
+	if err != nil {
+		v.err = err
+	}
+	return n, err
+}
+
+// Close completes the entry and releases the log
+func (v *valueWriter) Close() error {
+	if v.closed {
+		return nil
+	}
+	v.closed = true
+
+	w := v.w
+	defer w.mutex.Unlock()
+
+	if v.err == nil && v.remaining != 0 {
+		v.err = errValueSize
+	}
+	if v.err != nil {
+		return w.discard(v.start, w.header.pos, v.err)
+	}
+	return w.finishPut(1, w.header.pos-v.start, v.t)
+}
+
+// --------------------------------------------------------------------
+
+type bufferedValueWriter struct {
+	w         *LogWriter
+	key       []byte
+	val       []byte
+	remaining int64
+
+	closed bool
+}
+
+// Write buffers value data
+func (v *bufferedValueWriter) Write(p []byte) (int, error) {
+	if v.closed {
+		return 0, errClosed
+	} else if int64(len(p)) > v.remaining {
+		return 0, errValueSize
+	}
+
+	v.val = append(v.val, p...)
+	v.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+// Close writes the entry
+func (v *bufferedValueWriter) Close() error {
+	if v.closed {
+		return nil
+	}
+	v.closed = true
+
+	if v.remaining != 0 {
+		return errValueSize
+	}
+	return v.w.Put(v.key, v.val)
 }
@@ -1,10 +1,14 @@
 package ccdb
 
 import (
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -64,17 +68,17 @@ var _ = Describe("LogWriter", func() {
 		reader, err := OpenLog(fname)
 		Expect(err).NotTo(HaveOccurred())
 		defer reader.Close()
-		iter := reader.iterator()
+		iter := reader.Iterator()
 
 		// Write even more
 		doWrite("3")
 
-		var acc []logEntry
+		var acc []Entry
 		for iter.Next() {
 			acc = append(acc, *iter.Entry())
 		}
 		Expect(iter.Error()).NotTo(HaveOccurred())
-		Expect(acc).To(Equal([]logEntry{
+		Expect(acc).To(Equal([]Entry{
 			{Pos: 128, Key: []byte("key1"), Val: []byte("value1")},
 			{Pos: 140, Key: []byte("longerkey1"), Val: []byte("v1")},
 			{Pos: 154, Key: []byte("key2"), Val: []byte("value2")},
@@ -128,3 +132,448 @@ var _ = Describe("LogWriter", func() {
 	})
 
 })
+
+var _ = Describe("LogWriter (sync policies)", func() {
+	var dir, fname string
+
+	var committedPos = func() int64 {
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		return reader.header.pos
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = filepath.Join(dir, "data.ccl")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should commit on every put", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Sync: SyncAlways})
+		Expect(err).NotTo(HaveOccurred())
+		defer subject.Close()
+
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(committedPos()).To(Equal(int64(138)))
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(committedPos()).To(Equal(int64(148)))
+	})
+
+	It("should group concurrent commits", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Sync: SyncAlways})
+		Expect(err).NotTo(HaveOccurred())
+		defer subject.Close()
+
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				for j := 0; j < 10; j++ {
+					Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+				}
+			}()
+		}
+		wg.Wait()
+
+		Expect(subject.unsynced).To(Equal(0))
+		Expect(subject.synced).To(Equal(int64(2128)))
+		Expect(committedPos()).To(Equal(int64(2128)))
+	})
+
+	It("should commit every N entries", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Sync: SyncPolicy{EveryN: 3}})
+		Expect(err).NotTo(HaveOccurred())
+		defer subject.Close()
+
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(committedPos()).To(Equal(int64(128)))
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(committedPos()).To(Equal(int64(158)))
+	})
+
+	It("should commit in intervals", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Sync: SyncPolicy{Interval: 10 * time.Millisecond}})
+		Expect(err).NotTo(HaveOccurred())
+		defer subject.Close()
+
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Eventually(committedPos).Should(Equal(int64(138)))
+	})
+
+	It("should notify async writers", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Sync: SyncPolicy{EveryN: 2}})
+		Expect(err).NotTo(HaveOccurred())
+		defer subject.Close()
+
+		done1 := subject.PutAsync([]byte("key"), []byte("value"))
+		Consistently(done1).ShouldNot(Receive())
+
+		done2 := subject.PutAsync([]byte("key"), []byte("value"))
+		Eventually(done1).Should(Receive(BeNil()))
+		Eventually(done2).Should(Receive(BeNil()))
+		Expect(committedPos()).To(Equal(int64(148)))
+
+		done3 := subject.PutAsync([]byte("key"), []byte("value"))
+		Expect(subject.Flush()).To(Succeed())
+		Expect(done3).To(Receive(BeNil()))
+
+		Expect(subject.PutAsync(nil, []byte("value"))).To(Receive(Equal(errBlankKey)))
+	})
+
+})
+
+var _ = Describe("LogWriter (streaming)", func() {
+	var subject *LogWriter
+	var dir, fname string
+
+	var readAll = func() []Entry {
+		Expect(subject.Flush()).To(Succeed())
+
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		var acc []Entry
+		iter := reader.Iterator()
+		for iter.Next() {
+			acc = append(acc, *iter.Entry())
+		}
+		Expect(iter.Error()).NotTo(HaveOccurred())
+		return acc
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = filepath.Join(dir, "data.ccl")
+
+		var err error
+		subject, err = CreateLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key0"), []byte("value0"))).To(Succeed())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should put from readers", func() {
+		Expect(subject.PutReader([]byte("key1"), strings.NewReader("value1"), 6)).To(Succeed())
+		Expect(subject.PutReader([]byte("key2"), strings.NewReader("value2 and more"), 6)).To(Succeed())
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+			{Pos: 140, Key: []byte("key1"), Val: []byte("value1")},
+			{Pos: 152, Key: []byte("key2"), Val: []byte("value2")},
+		}))
+	})
+
+	It("should discard incomplete values from readers", func() {
+		Expect(subject.PutReader([]byte("key1"), strings.NewReader("val"), 6)).To(Equal(errValueSize))
+		Expect(subject.PutReader([]byte("key1"), strings.NewReader("value1"), 0)).To(Equal(errBlankValue))
+		Expect(subject.PutReader(nil, strings.NewReader("value1"), 6)).To(Equal(errBlankKey))
+		Expect(subject.Put([]byte("key2"), []byte("value2"))).To(Succeed())
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+			{Pos: 140, Key: []byte("key2"), Val: []byte("value2")},
+		}))
+	})
+
+	It("should put via writers", func() {
+		vw, err := subject.PutWriter([]byte("key1"), 6)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vw.Write([]byte("val"))).To(Equal(3))
+		Expect(vw.Write([]byte("ue1"))).To(Equal(3))
+		Expect(vw.Close()).To(Succeed())
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+			{Pos: 140, Key: []byte("key1"), Val: []byte("value1")},
+		}))
+	})
+
+	It("should discard incomplete values from writers", func() {
+		vw, err := subject.PutWriter([]byte("key1"), 6)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vw.Write([]byte("val"))).To(Equal(3))
+		Expect(vw.Close()).To(Equal(errValueSize))
+
+		vw, err = subject.PutWriter([]byte("key1"), 6)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = vw.Write([]byte("value1 and more"))
+		Expect(err).To(Equal(errValueSize))
+		Expect(vw.Close()).To(Equal(errValueSize))
+
+		_, err = vw.Write([]byte("value1"))
+		Expect(err).To(Equal(errClosed))
+		Expect(readAll()).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0")},
+		}))
+	})
+
+	It("should report failed rollbacks", func() {
+		Expect(subject.Flush()).To(Succeed())
+		Expect(subject.file.Close()).To(Succeed())
+
+		err := subject.PutReader([]byte("key1"), iotest.ErrReader(errValueSize), 6)
+		Expect(err).To(MatchError(ContainSubstring("rollback failed")))
+		Expect(subject.Put([]byte("key2"), []byte("value2"))).To(Equal(errWriterFailed))
+	})
+
+})
+
+var _ = Describe("LogWriter (options)", func() {
+	var dir, fname string
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = filepath.Join(dir, "data.ccl")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should validate", func() {
+		_, err := CreateLogWithOptions(fname, &LogOptions{BufferSize: -1})
+		Expect(err).To(Equal(ErrInvalidBufferSize))
+		_, err = CreateLogWithOptions(fname, &LogOptions{MaxValueSize: -1})
+		Expect(err).To(Equal(ErrInvalidSizeLimit))
+	})
+
+	It("should assign file IDs", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{FileID: 33})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.header.id).To(Equal(uint32(33)))
+		Expect(subject.Close()).To(Succeed())
+
+		_, err = AppendLogWithOptions(fname, &LogOptions{FileID: 34})
+		Expect(err).To(Equal(ErrFileIDMismatch))
+
+		subject, err = AppendLogWithOptions(fname, &LogOptions{FileID: 33})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Close()).To(Succeed())
+
+		subject, err = CreateLogWithOptions(fname, &LogOptions{Rand: strings.NewReader("\x00\x00\x00\x00\x01\x00\x00\x00")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.header.id).To(Equal(uint32(1)))
+		Expect(subject.Close()).To(Succeed())
+	})
+
+	It("should set permissions", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Perm: 0600})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Close()).To(Succeed())
+
+		info, err := os.Stat(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("should support exclusive creation", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Exclusive: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Close()).To(Succeed())
+
+		_, err = CreateLogWithOptions(fname, &LogOptions{Exclusive: true})
+		Expect(os.IsExist(err)).To(BeTrue())
+	})
+
+	It("should limit key and value sizes", func() {
+		subject, err := CreateLogWithOptions(fname, &LogOptions{MaxKeySize: 4, MaxValueSize: 6, BufferSize: 16})
+		Expect(err).NotTo(HaveOccurred())
+		defer subject.Close()
+
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(subject.Put([]byte("longkey"), []byte("value"))).To(Equal(ErrKeyTooLarge))
+		Expect(subject.Put([]byte("key"), []byte("longvalue"))).To(Equal(ErrValueTooLarge))
+		Expect(subject.PutReader([]byte("key"), strings.NewReader("longvalue"), 9)).To(Equal(ErrValueTooLarge))
+		Expect(subject.NewBatch().Put([]byte("key"), []byte("longvalue"))).To(Equal(ErrValueTooLarge))
+		Expect(subject.buffer.Size()).To(Equal(16))
+	})
+
+	It("should store metadata", func() {
+		meta := map[string]string{"producer": "test", "schema": "3"}
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Metadata: meta})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(subject.WriteIndex(fname + ".cci")).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		db, err := Open(fname+".cci", fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(db.Metadata()).To(Equal(meta))
+		Expect(db.index.Metadata()).To(Equal(meta))
+
+		subject, err = AppendLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		log, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer log.Close()
+		Expect(log.Metadata()).To(Equal(meta))
+
+		_, err = AppendLogWithOptions(fname, &LogOptions{Metadata: meta})
+		Expect(err).To(Equal(ErrMetadataImmutable))
+
+		_, err = CreateLogWithOptions(fname, &LogOptions{Metadata: map[string]string{"key": strings.Repeat("x", 70000)}})
+		Expect(err).To(Equal(ErrMetadataTooLarge))
+	})
+
+	It("should store extended metadata", func() {
+		meta := map[string]string{"schema": strings.Repeat("x", 1000)}
+		subject, err := CreateLogWithOptions(fname, &LogOptions{Metadata: meta})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Size()).To(Equal(int64(fileHeaderLen + 4 + 1010)))
+		Expect(subject.Put([]byte("key"), []byte("value1"))).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		subject, err = AppendLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key"), []byte("value2"))).To(Succeed())
+		Expect(subject.WriteIndex(fname + ".cci")).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		db, err := Open(fname+".cci", fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(db.Info().MinorVersion).To(Equal(uint16(2)))
+		Expect(db.Metadata()).To(Equal(meta))
+		Expect(db.index.Metadata()).To(Equal(meta))
+
+		iter, err := db.Get([]byte("key"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.All()).To(Equal([][]byte{[]byte("value1"), []byte("value2")}))
+	})
+
+})
+
+var _ = Describe("LogWriter (timestamps)", func() {
+	var subject *LogWriter
+	var dir, fname string
+	var now time.Time
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		fname = filepath.Join(dir, "data.ccl")
+		now = time.Unix(1500000000, 0)
+
+		var err error
+		subject, err = CreateLogWithOptions(fname, &LogOptions{Timestamps: true, Clock: func() time.Time { return now }})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should validate", func() {
+		Expect(subject.PutWithTTL([]byte("key"), []byte("value"), -time.Second)).To(Equal(ErrInvalidTTL))
+		Expect(subject.PutWithTTL([]byte("key"), []byte("value"), math.MaxInt64)).To(Equal(ErrInvalidTTL))
+		Expect(subject.NewBatch().PutWithTTL([]byte("key"), []byte("value"), math.MaxInt64)).To(Equal(ErrInvalidTTL))
+
+		plain, err := CreateLog(filepath.Join(dir, "plain.ccl"))
+		Expect(err).NotTo(HaveOccurred())
+		defer plain.Close()
+		Expect(plain.PutWithTTL([]byte("key"), []byte("value"), time.Second)).To(Equal(ErrNoTimestamps))
+		Expect(plain.NewBatch().PutWithTTL([]byte("key"), []byte("value"), time.Second)).To(Equal(ErrNoTimestamps))
+		Expect(plain.PutWithTTL([]byte("key"), []byte("value"), 0)).To(Succeed())
+	})
+
+	It("should store timestamps and TTLs", func() {
+		Expect(subject.Put([]byte("key0"), []byte("value0"))).To(Succeed())
+		now = now.Add(time.Second)
+		Expect(subject.PutWithTTL([]byte("key1"), []byte("value1"), 1500*time.Millisecond)).To(Succeed())
+		batch := subject.NewBatch()
+		Expect(batch.PutWithTTL([]byte("key2"), []byte("value2"), time.Minute)).To(Succeed())
+		Expect(batch.Commit()).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		Expect(reader.Info().MinorVersion).To(Equal(uint16(1)))
+
+		var acc []Entry
+		iter := reader.Iterator()
+		for iter.Next() {
+			acc = append(acc, *iter.Entry())
+		}
+		Expect(iter.Error()).NotTo(HaveOccurred())
+		Expect(acc).To(Equal([]Entry{
+			{Pos: 128, Key: []byte("key0"), Val: []byte("value0"), Timestamp: now.Add(-time.Second)},
+			{Pos: 146, Key: []byte("key1"), Val: []byte("value1"), Timestamp: now, TTL: 2 * time.Second},
+			{Pos: 164, Key: []byte("key2"), Val: []byte("value2"), Timestamp: now, TTL: time.Minute},
+		}))
+
+		Expect(acc[0].Expired(now.Add(time.Hour))).To(BeFalse())
+		Expect(acc[1].Expired(now.Add(time.Second))).To(BeFalse())
+		Expect(acc[1].Expired(now.Add(2 * time.Second))).To(BeTrue())
+
+		ent, err := reader.GetEntry(146)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*ent).To(Equal(acc[1]))
+	})
+
+	It("should store the largest TTL", func() {
+		Expect(subject.PutWithTTL([]byte("key"), []byte("value"), maxTTL-time.Millisecond)).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		ent, err := reader.GetEntry(128)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ent.TTL).To(Equal(maxTTL))
+		Expect(ent.Expired(now.Add(time.Hour))).To(BeFalse())
+	})
+
+	It("should skip expired values", func() {
+		Expect(subject.PutWithTTL([]byte("key"), []byte("short"), time.Second)).To(Succeed())
+		Expect(subject.PutWithTTL([]byte("key"), []byte("long"), time.Hour)).To(Succeed())
+		Expect(subject.Put([]byte("key"), []byte("forever"))).To(Succeed())
+		Expect(subject.Flush()).To(Succeed())
+
+		Expect(WriteIndexAt(fname+".cci", fname, now)).To(Succeed())
+		db, err := Open(fname+".cci", fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		var getAt = func(t time.Time) []string {
+			iter, err := db.GetAt([]byte("key"), t)
+			Expect(err).NotTo(HaveOccurred())
+			vals, err := iter.All()
+			Expect(err).NotTo(HaveOccurred())
+
+			var acc []string
+			for _, val := range vals {
+				acc = append(acc, string(val))
+			}
+			return acc
+		}
+		Expect(getAt(now)).To(Equal([]string{"short", "long", "forever"}))
+		Expect(getAt(now.Add(time.Minute))).To(Equal([]string{"long", "forever"}))
+		Expect(getAt(now.Add(2 * time.Hour))).To(Equal([]string{"forever"}))
+
+		Expect(WriteIndexAt(fname+".cci", fname, now.Add(time.Minute))).To(Succeed())
+		index, err := OpenIndex(fname + ".cci")
+		Expect(err).NotTo(HaveOccurred())
+		defer index.Close()
+
+		stats, err := index.Stats()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Entries).To(Equal(int64(2)))
+	})
+
+})
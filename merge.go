@@ -0,0 +1,229 @@
+package ccdb
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MergeOptions can be used to configure log merges
+type MergeOptions struct {
+	// Less interleaves entries from all sources, assuming that each source
+	// is already ordered accordingly. Ties are resolved by source order.
+	// Default: sources are concatenated in order.
+	Less func(a, b *Entry) bool
+
+	// Dedupe drops repeated, identical key/value pairs.
+	Dedupe bool
+
+	// KeepExpired retains entries with expired TTLs.
+	// Default: expired entries are dropped.
+	KeepExpired bool
+
+	// Now is the time at which TTLs are evaluated. Default: time.Now()
+	Now time.Time
+
+	// Index is the path of the index file.
+	// Default: the destination path with a .cci extension.
+	Index string
+
+	// Log options are applied to the destination log. Timestamps
+	// are enabled automatically if any of the sources has timestamps.
+	// Encrypted sources are decrypted using the same key provider.
+	Log *LogOptions
+}
+
+// MergeLogs concatenates entries from multiple logs
+// into a new log at dst and writes a matching index
+func MergeLogs(dst string, srcs ...string) error {
+	return MergeLogsWithOptions(dst, nil, srcs...)
+}
+
+// MergeLogsWithOptions merges multiple logs into a new log at dst using custom options.
+// The new log is assigned a fresh file ID, unless specified otherwise.
+func MergeLogsWithOptions(dst string, opt *MergeOptions, srcs ...string) error {
+	if opt == nil {
+		opt = new(MergeOptions)
+	}
+	if opt.Now.IsZero() {
+		o := *opt
+		o.Now = time.Now()
+		opt = &o
+	}
+
+	index := opt.Index
+	if index == "" {
+		index = strings.TrimSuffix(dst, filepath.Ext(dst)) + ".cci"
+	}
+
+	ropt := new(ReadOptions)
+	if opt.Log != nil {
+		ropt.Keys = opt.Log.Keys
+	}
+
+	readers := make([]*LogReader, 0, len(srcs))
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+
+	// compare files rather than paths, to catch relative paths and links
+	dstInfo, err := os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, src := range srcs {
+		reader, err := OpenLogWithOptions(src, ropt)
+		if err != nil {
+			return err
+		}
+		readers = append(readers, reader)
+
+		if dstInfo != nil {
+			srcInfo, err := reader.file.Stat()
+			if err != nil {
+				return err
+			}
+			if os.SameFile(srcInfo, dstInfo) {
+				return errMergeDestination
+			}
+		}
+	}
+
+	logOpt := new(LogOptions)
+	if opt.Log != nil {
+		*logOpt = *opt.Log
+	}
+	for _, r := range readers {
+		if r.header.timestamps() {
+			logOpt.Timestamps = true
+		}
+	}
+
+	writer, err := CreateLogWithOptions(dst, logOpt)
+	if err != nil {
+		return err
+	}
+
+	if err := mergeLogs(writer, readers, opt); err != nil {
+		writer.Close()
+		removeLog(dst, index)
+		return err
+	}
+	if err := writer.WriteIndex(index); err != nil {
+		writer.Close()
+		removeLog(dst, index)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		removeLog(dst, index)
+		return err
+	}
+	return nil
+}
+
+// removeLog removes the log and index files of a failed merge
+func removeLog(logFile, indexFile string) {
+	os.Remove(logFile)
+	os.Remove(indexFile)
+}
+
+func mergeLogs(w *LogWriter, readers []*LogReader, opt *MergeOptions) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// copy raw data, if possible
+	if canCopyRaw(w, readers, opt) {
+		for _, r := range readers {
+			start := r.header.dataOffset()
+			n := r.header.pos - start
+			if err := w.writeRaw(io.NewSectionReader(r.file, start, n), n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	iters := make([]*LogIterator, 0, len(readers))
+	for _, r := range readers {
+		iter := r.Iterator()
+		if iter.Next() {
+			iters = append(iters, iter)
+		} else if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[[sha256.Size]byte]struct{})
+	for len(iters) != 0 {
+		n := 0
+		if opt.Less != nil {
+			for i := 1; i < len(iters); i++ {
+				if opt.Less(iters[i].Entry(), iters[n].Entry()) {
+					n = i
+				}
+			}
+		}
+
+		iter := iters[n]
+		if ent := iter.Entry(); (opt.KeepExpired || !ent.Expired(opt.Now)) && (!opt.Dedupe || !isDuplicate(seen, ent)) {
+			if err := w.writeEntry(ent.Key, ent.Val, ent.Timestamp, ent.TTL); err != nil {
+				return err
+			}
+		}
+
+		if !iter.Next() {
+			if err := iter.Error(); err != nil {
+				return err
+			}
+			iters = append(iters[:n], iters[n+1:]...)
+		}
+	}
+	return nil
+}
+
+// canCopyRaw returns true if entries can be copied without
+// re-encoding, interleaving or filtering
+func canCopyRaw(w *LogWriter, readers []*LogReader, opt *MergeOptions) bool {
+	if opt.Less != nil || opt.Dedupe {
+		return false
+	}
+	if w.header.encrypted() {
+		return false
+	}
+	for _, r := range readers {
+		if r.header.encrypted() {
+			return false
+		} else if r.header.timestamps() != w.header.timestamps() {
+			return false
+		} else if r.header.timestamps() && !opt.KeepExpired {
+			return false
+		}
+	}
+	return true
+}
+
+// isDuplicate returns true if the key/value pair
+// has been seen before, otherwise marks it as seen
+func isDuplicate(seen map[[sha256.Size]byte]struct{}, ent *Entry) bool {
+	klen := make([]byte, binary.MaxVarintLen64)
+	hash := sha256.New()
+	hash.Write(klen[:binary.PutUvarint(klen, uint64(len(ent.Key)))])
+	hash.Write(ent.Key)
+	hash.Write(ent.Val)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+
+	if _, ok := seen[sum]; ok {
+		return true
+	}
+	seen[sum] = struct{}{}
+	return false
+}
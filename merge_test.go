@@ -0,0 +1,140 @@
+package ccdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MergeLogs", func() {
+	var dir, dst string
+	var srcs []string
+
+	var writeLog = func(name string, kvs ...string) string {
+		fname := filepath.Join(dir, name)
+		writer, err := CreateLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < len(kvs); i += 2 {
+			Expect(writer.Put([]byte(kvs[i]), []byte(kvs[i+1]))).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+		return fname
+	}
+
+	var readAll = func() []string {
+		reader, err := OpenLog(dst)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		var acc []string
+		iter := reader.Iterator()
+		for iter.Next() {
+			acc = append(acc, string(iter.Entry().Key)+"="+string(iter.Entry().Val))
+		}
+		Expect(iter.Error()).NotTo(HaveOccurred())
+		return acc
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		dst = filepath.Join(dir, "merged.ccl")
+		srcs = []string{
+			writeLog("a.ccl", "a", "1", "c", "3", "e", "5"),
+			writeLog("b.ccl", "b", "2", "c", "3", "d", "4"),
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should concatenate", func() {
+		Expect(MergeLogs(dst, srcs...)).To(Succeed())
+		Expect(readAll()).To(Equal([]string{"a=1", "c=3", "e=5", "b=2", "c=3", "d=4"}))
+
+		db, err := Open(filepath.Join(dir, "merged.cci"), dst)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		iter, err := db.Get([]byte("c"))
+		Expect(err).NotTo(HaveOccurred())
+		vals, err := iter.All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vals).To(HaveLen(2))
+
+		src, err := OpenLog(srcs[0])
+		Expect(err).NotTo(HaveOccurred())
+		defer src.Close()
+		Expect(db.log.header.id).NotTo(Equal(src.header.id))
+	})
+
+	It("should dedupe", func() {
+		Expect(MergeLogsWithOptions(dst, &MergeOptions{Dedupe: true}, srcs...)).To(Succeed())
+		Expect(readAll()).To(Equal([]string{"a=1", "c=3", "e=5", "b=2", "d=4"}))
+	})
+
+	It("should interleave", func() {
+		less := func(a, b *Entry) bool { return bytes.Compare(a.Key, b.Key) < 0 }
+		index := filepath.Join(dir, "custom.cci")
+		Expect(MergeLogsWithOptions(dst, &MergeOptions{Less: less, Index: index}, srcs...)).To(Succeed())
+		Expect(readAll()).To(Equal([]string{"a=1", "b=2", "c=3", "c=3", "d=4", "e=5"}))
+
+		_, err := os.Stat(index)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should drop expired entries", func() {
+		now := time.Unix(1500000000, 0)
+		fname := filepath.Join(dir, "c.ccl")
+		writer, err := CreateLogWithOptions(fname, &LogOptions{Timestamps: true, Clock: func() time.Time { return now }})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.PutWithTTL([]byte("f"), []byte("6"), time.Minute)).To(Succeed())
+		Expect(writer.PutWithTTL([]byte("g"), []byte("7"), time.Hour)).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		Expect(MergeLogsWithOptions(dst, &MergeOptions{Now: now.Add(30 * time.Minute)}, srcs[0], fname)).To(Succeed())
+		Expect(readAll()).To(Equal([]string{"a=1", "c=3", "e=5", "g=7"}))
+
+		reader, err := OpenLog(dst)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		Expect(reader.Info().Timestamps).To(BeTrue())
+
+		ent, err := reader.GetEntry(158)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ent).To(Equal(&Entry{Pos: 158, Key: []byte("g"), Val: []byte("7"), Timestamp: now, TTL: time.Hour}))
+
+		Expect(MergeLogsWithOptions(dst, &MergeOptions{Now: now.Add(30 * time.Minute), KeepExpired: true}, fname)).To(Succeed())
+		Expect(readAll()).To(Equal([]string{"f=6", "g=7"}))
+	})
+
+	It("should reject bad destinations", func() {
+		Expect(MergeLogs(srcs[0], srcs...)).To(Equal(errMergeDestination))
+
+		link := filepath.Join(dir, "link.ccl")
+		Expect(os.Symlink(srcs[1], link)).To(Succeed())
+		Expect(MergeLogs(link, srcs...)).To(Equal(errMergeDestination))
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Chdir(wd)
+		Expect(os.Chdir(dir)).To(Succeed())
+		Expect(MergeLogs(srcs[0], "a.ccl")).To(Equal(errMergeDestination))
+
+		dst = srcs[0]
+		Expect(readAll()).To(Equal([]string{"a=1", "c=3", "e=5"}))
+	})
+
+	It("should clean up on failures", func() {
+		index := filepath.Join(dir, "missing", "merged.cci")
+		Expect(MergeLogsWithOptions(dst, &MergeOptions{Index: index}, srcs...)).NotTo(Succeed())
+
+		_, err := os.Stat(dst)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+})
@@ -0,0 +1,73 @@
+package ccdb
+
+import "time"
+
+// MultiOrder determines the order in which multiple databases are queried
+type MultiOrder int
+
+const (
+	// OldestFirst queries databases in the given order
+	OldestFirst MultiOrder = iota
+	// NewestFirst queries databases in reverse order
+	NewestFirst
+)
+
+// MultiOptions can be used to configure multi-databases
+type MultiOptions struct {
+	// Order determines the order of returned values. Default: OldestFirst
+	Order MultiOrder
+
+	// FirstMatch stops iteration after the first database containing the key.
+	FirstMatch bool
+
+	// Observer receives one lookup event per Get. Observers of the
+	// individual databases receive all other events. Default: none
+	Observer Observer
+}
+
+// MultiDB is a read-only abstraction of multiple databases
+// and merges lookups across all of them
+type MultiDB struct {
+	dbs        []*DB // in query order
+	firstMatch bool
+	obs        Observer
+}
+
+// NewMultiDB combines multiple databases, which must be passed
+// oldest first. Closing the MultiDB will close all databases.
+func NewMultiDB(dbs []*DB, opt *MultiOptions) *MultiDB {
+	if opt == nil {
+		opt = new(MultiOptions)
+	}
+
+	ordered := make([]*DB, len(dbs))
+	copy(ordered, dbs)
+	if opt.Order == NewestFirst {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+	return &MultiDB{dbs: ordered, firstMatch: opt.FirstMatch, obs: opt.Observer}
+}
+
+// Close closes all databases
+func (m *MultiDB) Close() error {
+	var err error
+	for _, db := range m.dbs {
+		if e := db.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Get retrieves a key and returns an iterator over
+// the matching values from all databases
+func (m *MultiDB) Get(key []byte) (*Iterator, error) {
+	iter, err := newIterator(key, m.dbs, time.Now(), m.obs)
+	if err != nil {
+		return nil, err
+	}
+	iter.firstMatch = m.firstMatch
+	return iter, nil
+}
@@ -0,0 +1,94 @@
+package ccdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MultiDB", func() {
+	var dir string
+	var dbs []*DB
+
+	var openDB = func(name string, kvs ...string) *DB {
+		lname, iname := filepath.Join(dir, name+".ccl"), filepath.Join(dir, name+".cci")
+		writer, err := CreateLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < len(kvs); i += 2 {
+			Expect(writer.Put([]byte(kvs[i]), []byte(kvs[i+1]))).To(Succeed())
+		}
+		Expect(writer.WriteIndex(iname)).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		db, err := Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		return db
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		dbs = nil
+		for i := 1; i <= 3; i++ {
+			day := fmt.Sprintf("day%d", i)
+			dbs = append(dbs, openDB(day, "a", day+".1", "a", day+".2", day, "x"))
+		}
+		dbs = append(dbs, openDB("day4", "b", "day4.1"))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should merge lookups oldest first", func() {
+		subject := NewMultiDB(dbs, nil)
+		defer subject.Close()
+
+		Expect(getValues(subject, "a")).To(Equal([]string{"day1.1", "day1.2", "day2.1", "day2.2", "day3.1", "day3.2"}))
+		Expect(getValues(subject, "b")).To(Equal([]string{"day4.1"}))
+		Expect(getValues(subject, "day2")).To(Equal([]string{"x"}))
+		Expect(getValues(subject, "c")).To(BeEmpty())
+	})
+
+	It("should merge lookups newest first", func() {
+		subject := NewMultiDB(dbs, &MultiOptions{Order: NewestFirst})
+		defer subject.Close()
+
+		Expect(getValues(subject, "a")).To(Equal([]string{"day3.1", "day3.2", "day2.1", "day2.2", "day1.1", "day1.2"}))
+	})
+
+	It("should stop at the first match", func() {
+		subject := NewMultiDB(dbs, &MultiOptions{Order: NewestFirst, FirstMatch: true})
+		defer subject.Close()
+
+		Expect(getValues(subject, "a")).To(Equal([]string{"day3.1", "day3.2"}))
+		Expect(getValues(subject, "day1")).To(Equal([]string{"x"}))
+		Expect(getValues(subject, "c")).To(BeEmpty())
+	})
+
+	It("should observe lookups", func() {
+		metrics := new(Metrics)
+		subject := NewMultiDB(dbs, &MultiOptions{Observer: metrics})
+		defer subject.Close()
+
+		Expect(getValues(subject, "b")).To(Equal([]string{"day4.1"}))
+		Expect(getValues(subject, "c")).To(BeEmpty())
+
+		s := metrics.Snapshot()
+		Expect(s.Lookups).To(Equal(int64(2)))
+		Expect(s.Hits).To(Equal(int64(1)))
+	})
+
+	It("should support empty sets", func() {
+		for _, db := range dbs {
+			Expect(db.Close()).To(Succeed())
+		}
+
+		subject := NewMultiDB(nil, nil)
+		defer subject.Close()
+		Expect(getValues(subject, "a")).To(BeEmpty())
+	})
+
+})
@@ -0,0 +1,196 @@
+package ccdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives events from readers and writers, i.e. to collect
+// metrics. Implementations must be safe for concurrent use.
+type Observer interface {
+	// ObserveLookup is called once per DB lookup, as soon as the
+	// first value is found or the key is known to be missing.
+	ObserveLookup(hit bool)
+
+	// ObserveProbe is called by IndexIterator.Next with the number of
+	// index slots probed.
+	ObserveProbe(slots int)
+
+	// ObserveCollision is called by Iterator.Next for entries with a
+	// matching checksum but a different key.
+	ObserveCollision()
+
+	// ObserveRead is called with the number of bytes read from a log.
+	ObserveRead(n int)
+
+	// ObservePut is called after entries are written and committed,
+	// according to the sync policy, with the number of entries, their
+	// size in bytes and the time taken.
+	ObservePut(entries int, size int64, d time.Duration)
+
+	// ObserveFlush is called after data is committed to stable storage,
+	// with the number of committed bytes and the time taken.
+	ObserveFlush(size int64, d time.Duration)
+}
+
+// NopObserver ignores all events. It can be embedded by
+// observers which are only interested in some events.
+type NopObserver struct{}
+
+// ObserveLookup implements Observer
+func (NopObserver) ObserveLookup(bool) {}
+
+// ObserveProbe implements Observer
+func (NopObserver) ObserveProbe(int) {}
+
+// ObserveCollision implements Observer
+func (NopObserver) ObserveCollision() {}
+
+// ObserveRead implements Observer
+func (NopObserver) ObserveRead(int) {}
+
+// ObservePut implements Observer
+func (NopObserver) ObservePut(int, int64, time.Duration) {}
+
+// ObserveFlush implements Observer
+func (NopObserver) ObserveFlush(int64, time.Duration) {}
+
+// observedReaderAt reports all reads to an observer
+type observedReaderAt struct {
+	io.ReaderAt
+	obs Observer
+}
+
+func (r observedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ReaderAt.ReadAt(p, off)
+	r.obs.ObserveRead(n)
+	return n, err
+}
+
+// --------------------------------------------------------------------
+
+// Metrics is an Observer which collects counters. It implements
+// expvar.Var, i.e. expvar.Publish("ccdb", metrics), and can write
+// the Prometheus text format. The zero value is ready to use.
+type Metrics struct {
+	lookups, hits, probes, maxProbe, collisions, bytesRead int64
+	puts, putEntries, putBytes, putNanos                   int64
+	flushes, flushBytes, flushNanos                        int64
+}
+
+// MetricsSnapshot contains the values of Metrics at a point in time
+type MetricsSnapshot struct {
+	Lookups    int64 `json:"lookups"`     // number of lookups
+	Hits       int64 `json:"hits"`        // number of lookups which found the key
+	Misses     int64 `json:"misses"`      // number of lookups which did not find the key
+	Probes     int64 `json:"probes"`      // total number of index slots probed
+	MaxProbe   int64 `json:"max_probe"`   // maximum number of slots probed by a single call
+	Collisions int64 `json:"collisions"`  // number of checksum collisions
+	BytesRead  int64 `json:"bytes_read"`  // bytes read from logs
+	Puts       int64 `json:"puts"`        // number of put operations
+	PutEntries int64 `json:"put_entries"` // number of written entries
+	PutBytes   int64 `json:"put_bytes"`   // bytes written
+	PutTime    int64 `json:"put_ns"`      // total time spent in put operations, in nanoseconds
+	Flushes    int64 `json:"flushes"`     // number of commits to stable storage
+	FlushBytes int64 `json:"flush_bytes"` // bytes committed
+	FlushTime  int64 `json:"flush_ns"`    // total time spent in commits, in nanoseconds
+}
+
+// ObserveLookup implements Observer
+func (m *Metrics) ObserveLookup(hit bool) {
+	atomic.AddInt64(&m.lookups, 1)
+	if hit {
+		atomic.AddInt64(&m.hits, 1)
+	}
+}
+
+// ObserveProbe implements Observer
+func (m *Metrics) ObserveProbe(slots int) {
+	atomic.AddInt64(&m.probes, int64(slots))
+	for {
+		max := atomic.LoadInt64(&m.maxProbe)
+		if int64(slots) <= max || atomic.CompareAndSwapInt64(&m.maxProbe, max, int64(slots)) {
+			return
+		}
+	}
+}
+
+// ObserveCollision implements Observer
+func (m *Metrics) ObserveCollision() { atomic.AddInt64(&m.collisions, 1) }
+
+// ObserveRead implements Observer
+func (m *Metrics) ObserveRead(n int) { atomic.AddInt64(&m.bytesRead, int64(n)) }
+
+// ObservePut implements Observer
+func (m *Metrics) ObservePut(entries int, size int64, d time.Duration) {
+	atomic.AddInt64(&m.puts, 1)
+	atomic.AddInt64(&m.putEntries, int64(entries))
+	atomic.AddInt64(&m.putBytes, size)
+	atomic.AddInt64(&m.putNanos, int64(d))
+}
+
+// ObserveFlush implements Observer
+func (m *Metrics) ObserveFlush(size int64, d time.Duration) {
+	atomic.AddInt64(&m.flushes, 1)
+	atomic.AddInt64(&m.flushBytes, size)
+	atomic.AddInt64(&m.flushNanos, int64(d))
+}
+
+// Snapshot returns the current values
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Lookups:    atomic.LoadInt64(&m.lookups),
+		Hits:       atomic.LoadInt64(&m.hits),
+		Probes:     atomic.LoadInt64(&m.probes),
+		MaxProbe:   atomic.LoadInt64(&m.maxProbe),
+		Collisions: atomic.LoadInt64(&m.collisions),
+		BytesRead:  atomic.LoadInt64(&m.bytesRead),
+		Puts:       atomic.LoadInt64(&m.puts),
+		PutEntries: atomic.LoadInt64(&m.putEntries),
+		PutBytes:   atomic.LoadInt64(&m.putBytes),
+		PutTime:    atomic.LoadInt64(&m.putNanos),
+		Flushes:    atomic.LoadInt64(&m.flushes),
+		FlushBytes: atomic.LoadInt64(&m.flushBytes),
+		FlushTime:  atomic.LoadInt64(&m.flushNanos),
+	}
+	s.Misses = s.Lookups - s.Hits
+	return s
+}
+
+// String returns the current values as JSON, implements expvar.Var
+func (m *Metrics) String() string {
+	data, _ := json.Marshal(m.Snapshot())
+	return string(data)
+}
+
+// WritePrometheus writes the current values in the Prometheus text format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	s := m.Snapshot()
+	for _, metric := range []struct {
+		name, kind, help string
+		value            interface{}
+	}{
+		{"ccdb_lookups_total", "counter", "Number of lookups.", s.Lookups},
+		{"ccdb_lookup_hits_total", "counter", "Number of lookups which found the key.", s.Hits},
+		{"ccdb_lookup_misses_total", "counter", "Number of lookups which did not find the key.", s.Misses},
+		{"ccdb_index_probes_total", "counter", "Number of index slots probed.", s.Probes},
+		{"ccdb_index_probe_max", "gauge", "Maximum number of index slots probed by a single call.", s.MaxProbe},
+		{"ccdb_checksum_collisions_total", "counter", "Number of checksum collisions.", s.Collisions},
+		{"ccdb_log_read_bytes_total", "counter", "Bytes read from logs.", s.BytesRead},
+		{"ccdb_puts_total", "counter", "Number of put operations.", s.Puts},
+		{"ccdb_put_entries_total", "counter", "Number of written entries.", s.PutEntries},
+		{"ccdb_put_bytes_total", "counter", "Bytes written.", s.PutBytes},
+		{"ccdb_put_seconds_total", "counter", "Time spent in put operations.", time.Duration(s.PutTime).Seconds()},
+		{"ccdb_flushes_total", "counter", "Number of commits to stable storage.", s.Flushes},
+		{"ccdb_flush_bytes_total", "counter", "Bytes committed to stable storage.", s.FlushBytes},
+		{"ccdb_flush_seconds_total", "counter", "Time spent in commits to stable storage.", time.Duration(s.FlushTime).Seconds()},
+	} {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package ccdb
+
+import (
+	"bytes"
+	"expvar"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var dir string
+	var metrics *Metrics
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		metrics = new(Metrics)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should observe writes", func() {
+		writer, err := CreateLogWithOptions(filepath.Join(dir, "test.ccl"), &LogOptions{Observer: metrics})
+		Expect(err).NotTo(HaveOccurred())
+		defer writer.Close()
+
+		Expect(writer.Put([]byte("foo"), []byte("v1"))).To(Succeed())
+		Expect(writer.PutReader([]byte("bar"), bytes.NewReader([]byte("v2")), 2)).To(Succeed())
+
+		batch := writer.NewBatch()
+		Expect(batch.Put([]byte("baz"), []byte("v3"))).To(Succeed())
+		Expect(batch.Put([]byte("qux"), []byte("v4"))).To(Succeed())
+		Expect(batch.Commit()).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+
+		s := metrics.Snapshot()
+		Expect(s.Puts).To(Equal(int64(3)))
+		Expect(s.PutEntries).To(Equal(int64(4)))
+		Expect(s.PutBytes).To(Equal(int64(28)))
+		Expect(s.PutTime).To(BeNumerically(">", 0))
+		Expect(s.Flushes).To(Equal(int64(1)))
+		Expect(s.FlushBytes).To(Equal(int64(28)))
+	})
+
+	It("should observe async writes once committed", func() {
+		writer, err := CreateLogWithOptions(filepath.Join(dir, "test.ccl"), &LogOptions{Observer: metrics})
+		Expect(err).NotTo(HaveOccurred())
+		defer writer.Close()
+
+		done := writer.PutAsync([]byte("foo"), []byte("v1"))
+		Expect(metrics.Snapshot().Puts).To(Equal(int64(0)))
+
+		Expect(writer.Flush()).To(Succeed())
+		Expect(<-done).To(Succeed())
+		Expect(metrics.Snapshot().Puts).To(Equal(int64(1)))
+		Expect(metrics.Snapshot().PutBytes).To(Equal(int64(7)))
+	})
+
+	It("should observe reads", func() {
+		lname, iname, err := writeTestWithCollisions(dir, 2)
+		Expect(err).NotTo(HaveOccurred())
+
+		db, err := OpenWithOptions(iname, lname, &ReadOptions{Observer: metrics})
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		iter, err := db.Get([]byte("key.5405800"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.All()).To(HaveLen(2))
+
+		iter, err = db.Get([]byte("missing"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.Next()).To(BeFalse())
+
+		s := metrics.Snapshot()
+		Expect(s.Lookups).To(Equal(int64(2)))
+		Expect(s.Hits).To(Equal(int64(1)))
+		Expect(s.Misses).To(Equal(int64(1)))
+		Expect(s.Collisions).To(Equal(int64(2)))
+		Expect(s.Probes).To(BeNumerically(">=", 4))
+		Expect(s.MaxProbe).To(BeNumerically(">=", 1))
+		Expect(s.BytesRead).To(BeNumerically(">", 0))
+	})
+
+	It("should export", func() {
+		metrics.ObserveLookup(true)
+		metrics.ObserveLookup(false)
+		metrics.ObserveProbe(3)
+
+		Expect(metrics.String()).To(HavePrefix(`{"lookups":2,"hits":1,"misses":1,"probes":3,"max_probe":3,`))
+
+		var _ expvar.Var = metrics
+		var _ Observer = NopObserver{}
+
+		buf := new(bytes.Buffer)
+		Expect(metrics.WritePrometheus(buf)).To(Succeed())
+		Expect(buf.String()).To(HavePrefix("# HELP ccdb_lookups_total Number of lookups.\n# TYPE ccdb_lookups_total counter\nccdb_lookups_total 2\n"))
+		Expect(buf.String()).To(ContainSubstring("\nccdb_index_probe_max 3\n"))
+		Expect(buf.String()).To(ContainSubstring("\nccdb_flush_seconds_total 0\n"))
+	})
+
+})
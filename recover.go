@@ -0,0 +1,114 @@
+package ccdb
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// RecoverOptions configure log recovery
+type RecoverOptions struct {
+	// Salvage attempts to keep fully-written entries beyond
+	// the position recorded in the file header.
+	Salvage bool
+
+	// DryRun reports what would be done, without modifying the file.
+	DryRun bool
+}
+
+// RecoverReport describes the outcome of a log recovery
+type RecoverReport struct {
+	FileSize  int64 `json:"file_size"`  // original file size
+	HeaderPos int64 `json:"header_pos"` // original position, as recorded in the header
+	Pos       int64 `json:"pos"`        // recovered position
+
+	Entries   int   `json:"entries"`   // number of valid entries
+	Salvaged  int   `json:"salvaged"`  // number of entries salvaged beyond the original header position
+	Truncated int64 `json:"truncated"` // number of bytes truncated
+}
+
+// Repaired returns true if the log was (or, in dry-run mode, would be) modified
+func (r *RecoverReport) Repaired() bool {
+	return r.Pos != r.HeaderPos || r.Truncated != 0
+}
+
+func (r *RecoverReport) String() string {
+	return fmt.Sprintf("File size: %d\nHeader position: %d\nRecovered position: %d\nEntries: %d\nSalvaged: %d\nTruncated: %d\n",
+		r.FileSize, r.HeaderPos, r.Pos, r.Entries, r.Salvaged, r.Truncated)
+}
+
+// RecoverLog validates the header of a log file, walks all entries and
+// repairs torn writes, which may be the result of a crash during Flush.
+// Data beyond the last complete entry is truncated and the header position
+// is rewritten accordingly. Recovery must not run while the log is being
+// written to.
+func RecoverLog(fname string, opt *RecoverOptions) (*RecoverReport, error) {
+	if opt == nil {
+		opt = new(RecoverOptions)
+	}
+
+	flag := os.O_RDWR
+	if opt.DryRun {
+		flag = os.O_RDONLY
+	}
+
+	file, err := os.OpenFile(fname, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := readFileHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RecoverReport{
+		FileSize:  stat.Size(),
+		HeaderPos: header.pos,
+		Pos:       header.dataOffset(),
+	}
+
+	end := header.pos
+	if opt.Salvage || end > report.FileSize {
+		end = report.FileSize
+	}
+
+	iter := newLogIterator(file, header, end)
+	iter.skipVal = true
+	for iter.Next() {
+		if report.Pos >= header.pos {
+			report.Salvaged++
+		}
+		report.Entries++
+		report.Pos = iter.pos
+	}
+	if err, ok := iter.Error().(*os.PathError); ok {
+		return nil, err
+	}
+	report.Truncated = report.FileSize - report.Pos
+
+	if opt.DryRun || !report.Repaired() {
+		return report, nil
+	}
+
+	header.pos = report.Pos
+	if err := file.Truncate(header.pos); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := header.WriteTo(file); err != nil {
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,135 @@
+package ccdb
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RecoverLog", func() {
+	var dir, fname string
+
+	var readHeader = func() *fileHeader {
+		file, err := os.Open(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		header, err := readFileHeader(file)
+		Expect(err).NotTo(HaveOccurred())
+		return header
+	}
+
+	var writeHeader = func(header *fileHeader) {
+		file, err := os.OpenFile(fname, os.O_WRONLY, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		_, err = header.WriteTo(file)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	var appendRaw = func(data []byte) {
+		file, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		_, err = file.Write(data)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir = mkTemp()
+		fname, err = writeTestLog(dir, 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readHeader().pos).To(Equal(int64(191)))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should leave intact logs untouched", func() {
+		report, err := RecoverLog(fname, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Repaired()).To(BeFalse())
+		Expect(report).To(Equal(&RecoverReport{FileSize: 191, HeaderPos: 191, Pos: 191, Entries: 3}))
+	})
+
+	It("should truncate torn tails", func() {
+		appendRaw([]byte{8, 11, 'k', 'e'})
+
+		report, err := RecoverLog(fname, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Repaired()).To(BeTrue())
+		Expect(report).To(Equal(&RecoverReport{FileSize: 195, HeaderPos: 191, Pos: 191, Entries: 3, Truncated: 4}))
+
+		stat, err := os.Stat(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stat.Size()).To(Equal(int64(191)))
+	})
+
+	It("should rewind headers pointing beyond the last complete entry", func() {
+		header := readHeader()
+		header.pos = 200
+		writeHeader(header)
+		appendRaw([]byte{8, 11, 'k', 'e'})
+
+		report, err := RecoverLog(fname, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(Equal(&RecoverReport{FileSize: 195, HeaderPos: 200, Pos: 191, Entries: 3, Truncated: 4}))
+		Expect(readHeader().pos).To(Equal(int64(191)))
+
+		reader, err := OpenLog(fname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		key, val, err := reader.Get(170)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(key)).To(Equal("key.0002"))
+		Expect(string(val)).To(Equal("val.0002.00"))
+	})
+
+	It("should salvage complete entries beyond the header position", func() {
+		header := readHeader()
+		header.pos = 149
+		writeHeader(header)
+		appendRaw([]byte{8, 11, 'k', 'e'})
+
+		report, err := RecoverLog(fname, &RecoverOptions{Salvage: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(Equal(&RecoverReport{FileSize: 195, HeaderPos: 149, Pos: 191, Entries: 3, Salvaged: 2, Truncated: 4}))
+		Expect(readHeader().pos).To(Equal(int64(191)))
+	})
+
+	It("should discard entries beyond the header position by default", func() {
+		header := readHeader()
+		header.pos = 149
+		writeHeader(header)
+
+		report, err := RecoverLog(fname, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(Equal(&RecoverReport{FileSize: 191, HeaderPos: 149, Pos: 149, Entries: 1, Truncated: 42}))
+	})
+
+	It("should support dry-runs", func() {
+		appendRaw([]byte{8, 11, 'k', 'e'})
+
+		report, err := RecoverLog(fname, &RecoverOptions{DryRun: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Truncated).To(Equal(int64(4)))
+
+		stat, err := os.Stat(fname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stat.Size()).To(Equal(int64(195)))
+	})
+
+	It("should reject bad headers", func() {
+		Expect(os.Truncate(fname, 0)).To(Succeed())
+
+		_, err := RecoverLog(fname, nil)
+		Expect(err).To(Equal(errHeaderCorrupt))
+	})
+
+})
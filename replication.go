@@ -0,0 +1,288 @@
+package ccdb
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	replicationFileIDHeader = "X-Ccdb-File-Id"
+	replicationPosHeader    = "X-Ccdb-Pos"
+)
+
+// NewReplicationHandler returns a handler which serves committed log data to
+// followers. Followers request data from an offset, i.e. GET /?offset=1024
+// and receive all data up to the committed position of the log. Requests
+// from offset 0 receive the file header, followed by all entries.
+func NewReplicationHandler(logFileName string) http.Handler {
+	return replicationHandler(logFileName)
+}
+
+type replicationHandler string
+
+// ServeHTTP implements http.Handler
+func (h replicationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var offset int64
+	if s := r.URL.Query().Get("offset"); s != "" {
+		var err error
+		if offset, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	file, err := os.Open(string(h))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	header, err := readFileHeader(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if offset != 0 && (offset < header.dataOffset() || offset > header.pos) {
+		http.Error(w, errInvalidOffset.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	start, size := offset, header.pos-offset
+	if offset == 0 {
+		start = header.dataOffset()
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set(replicationFileIDHeader, strconv.FormatUint(uint64(header.id), 10))
+	w.Header().Set(replicationPosHeader, strconv.FormatInt(header.pos, 10))
+	if offset == 0 {
+		if _, err := header.WriteTo(w); err != nil {
+			return
+		}
+	}
+	io.Copy(w, io.NewSectionReader(file, start, header.pos-start))
+}
+
+// --------------------------------------------------------------------
+
+// FollowerOptions can be used to configure followers
+type FollowerOptions struct {
+	// Interval determines how often the leader is polled
+	// and how long to wait after errors. Default: 1s
+	Interval time.Duration
+
+	// Index rebuilds the index at the given path, whenever the
+	// follower has caught up with new data. Default: no index
+	Index string
+
+	// Client is used for requests to the leader. Default: http.DefaultClient
+	Client *http.Client
+
+	// OnError is called with errors which occur while running.
+	OnError func(error)
+}
+
+func (o *FollowerOptions) norm() *FollowerOptions {
+	var opt FollowerOptions
+	if o != nil {
+		opt = *o
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = time.Second
+	}
+	if opt.Client == nil {
+		opt.Client = http.DefaultClient
+	}
+	return &opt
+}
+
+// Follower replicates a log from a leader, see NewReplicationHandler
+type Follower struct {
+	url   string
+	fname string
+	opt   *FollowerOptions
+
+	mutex   sync.Mutex
+	indexed int64 // position covered by the last index
+}
+
+// NewFollower creates a follower which replicates the log served at
+// leaderURL into a local file. The local file is created with the
+// header of the leader, if it does not exist.
+func NewFollower(leaderURL, fname string, opt *FollowerOptions) *Follower {
+	return &Follower{url: leaderURL, fname: fname, opt: opt.norm()}
+}
+
+// Run polls the leader until stop is closed.
+// Errors are reported and retried after the polling interval.
+func (f *Follower) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(f.opt.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.Sync(); err != nil && f.opt.OnError != nil {
+			f.opt.OnError(err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches new data from the leader once. Only complete entries are
+// appended, incomplete transfers are resumed by the next call.
+func (f *Follower) Sync() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, header, err := f.openLocal()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var offset int64
+	if header != nil {
+		offset = header.pos
+	}
+
+	resp, err := f.opt.Client.Get(f.url + "?offset=" + url.QueryEscape(strconv.FormatInt(offset, 10)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ccdb: replication failed with status %d", resp.StatusCode)
+	}
+
+	id, err1 := strconv.ParseUint(resp.Header.Get(replicationFileIDHeader), 10, 32)
+	leaderPos, err2 := strconv.ParseInt(resp.Header.Get(replicationPosHeader), 10, 64)
+	if err1 != nil || err2 != nil {
+		return errHeaderCorrupt
+	}
+
+	if header == nil {
+		if header, err = bootstrap(file, resp.Body, leaderPos); err != nil {
+			os.Remove(f.fname)
+			return err
+		}
+	}
+
+	if uint32(id) != header.id {
+		return ErrFileIDMismatch
+	} else if leaderPos < header.pos {
+		return errReplicaAhead
+	}
+
+	if err := f.append(file, header, resp.Body, leaderPos-header.pos); err != nil {
+		return err
+	}
+
+	if f.opt.Index != "" && header.pos == leaderPos && header.pos != f.indexed {
+		if err := f.writeIndex(); err != nil {
+			return err
+		}
+		f.indexed = header.pos
+	}
+	return nil
+}
+
+// openLocal opens the local log, returns a nil header if it was just created
+func (f *Follower) openLocal() (*os.File, *fileHeader, error) {
+	file, err := os.OpenFile(f.fname, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		file, err = os.OpenFile(f.fname, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		return file, nil, err
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	header, err := readFileHeader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, header, nil
+}
+
+// bootstrap reads the leader header from r and writes it to a new log file
+func bootstrap(file *os.File, r io.Reader, leaderPos int64) (*fileHeader, error) {
+	header, err := readFileHeader(io.LimitReader(r, maxFileHeaderLen))
+	if err != nil {
+		return nil, err
+	} else if header.pos != leaderPos {
+		return nil, errHeaderCorrupt
+	}
+
+	header.pos = header.dataOffset()
+	if _, err := header.WriteTo(file); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// append copies up to n bytes from r and commits all complete entries
+func (f *Follower) append(file *os.File, header *fileHeader, r io.Reader, n int64) error {
+	start := header.pos
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	m, copyErr := io.Copy(file, io.LimitReader(r, n))
+	if copyErr == nil && m < n {
+		copyErr = io.ErrUnexpectedEOF
+	}
+
+	// validate new entries, discard incomplete data
+	pos := start
+	iter := newLogIteratorAt(file, header, start, start+m)
+	iter.skipVal = true
+	for iter.Next() {
+		pos = iter.pos
+	}
+	if err := iter.Error(); err != nil && err != io.ErrUnexpectedEOF {
+		copyErr = err
+	}
+
+	header.pos = pos
+	if err := file.Truncate(header.pos); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := header.WriteTo(file); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// writeIndex atomically replaces the index
+func (f *Follower) writeIndex() error {
+	tmp := filepath.Join(filepath.Dir(f.opt.Index), "."+filepath.Base(f.opt.Index)+".tmp")
+	if err := WriteIndex(tmp, f.fname); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, f.opt.Index)
+}
@@ -0,0 +1,170 @@
+package ccdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Follower", func() {
+	var dir, leader, replica, index string
+	var writer *LogWriter
+	var server *httptest.Server
+	var subject *Follower
+	var truncate int64
+
+	BeforeEach(func() {
+		dir = mkTemp()
+		leader = filepath.Join(dir, "leader.ccl")
+		replica = filepath.Join(dir, "replica.ccl")
+		index = filepath.Join(dir, "replica.cci")
+		truncate = 0
+
+		var err error
+		writer, err = CreateLogWithOptions(leader, &LogOptions{FileID: 0x1234})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Put([]byte("foo"), []byte("v1"))).To(Succeed())
+		Expect(writer.Put([]byte("bar"), []byte("v2"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+
+		handler := NewReplicationHandler(leader)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if truncate == 0 {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			// cut the body short to simulate a disconnect
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			for name, vv := range rec.Header() {
+				w.Header()[name] = vv
+			}
+			w.Header().Del("Content-Length")
+			w.WriteHeader(rec.Code)
+			w.Write(rec.Body.Bytes()[:truncate])
+		}))
+
+		subject = NewFollower(server.URL, replica, &FollowerOptions{Index: index})
+	})
+
+	AfterEach(func() {
+		server.Close()
+		writer.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should bootstrap", func() {
+		Expect(subject.Sync()).To(Succeed())
+
+		leaderData, err := ioutil.ReadFile(leader)
+		Expect(err).NotTo(HaveOccurred())
+		replicaData, err := ioutil.ReadFile(replica)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replicaData).To(Equal(leaderData))
+
+		Expect(readValues(index, replica, "foo")).To(Equal([]string{"v1"}))
+		Expect(readValues(index, replica, "bar")).To(Equal([]string{"v2"}))
+	})
+
+	It("should append new entries", func() {
+		Expect(subject.Sync()).To(Succeed())
+
+		Expect(writer.Put([]byte("foo"), []byte("v3"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+		Expect(subject.Sync()).To(Succeed())
+		Expect(subject.Sync()).To(Succeed())
+
+		Expect(readValues(index, replica, "foo")).To(Equal([]string{"v1", "v3"}))
+
+		info, err := os.Stat(replica)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(Equal(writer.Size()))
+	})
+
+	It("should not replicate uncommitted data", func() {
+		Expect(writer.Put([]byte("foo"), []byte("v3"))).To(Succeed())
+		Expect(subject.Sync()).To(Succeed())
+		Expect(readValues(index, replica, "foo")).To(Equal([]string{"v1"}))
+	})
+
+	It("should resume after disconnects", func() {
+		Expect(subject.Sync()).To(Succeed())
+
+		Expect(writer.Put([]byte("foo"), []byte("v3"))).To(Succeed())
+		Expect(writer.Put([]byte("foo"), []byte("v4"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+
+		// entries are 7 bytes each, cut into the second
+		truncate = 10
+		Expect(subject.Sync()).To(HaveOccurred())
+
+		info, err := os.Stat(replica)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(Equal(int64(fileHeaderLen + 3*7)))
+
+		truncate = 0
+		Expect(subject.Sync()).To(Succeed())
+		Expect(readValues(index, replica, "foo")).To(Equal([]string{"v1", "v3", "v4"}))
+	})
+
+	It("should not create a replica on failed bootstraps", func() {
+		truncate = 100
+		Expect(subject.Sync()).To(Equal(errHeaderCorrupt))
+
+		_, err := os.Stat(replica)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should reject mismatching logs", func() {
+		other, err := CreateLogWithOptions(replica, &LogOptions{FileID: 0x4321})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(other.Close()).To(Succeed())
+
+		Expect(subject.Sync()).To(Equal(ErrFileIDMismatch))
+	})
+
+	It("should reject invalid offsets", func() {
+		resp, err := http.Get(server.URL + "?offset=" + strconv.Itoa(fileHeaderLen-1))
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusRequestedRangeNotSatisfiable))
+
+		resp, err = http.Get(server.URL + "?offset=" + strconv.FormatInt(writer.Size()+1, 10))
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusRequestedRangeNotSatisfiable))
+	})
+
+	It("should run until stopped", func() {
+		Expect(subject.Sync()).To(Succeed())
+
+		errs := make(chan error, 10)
+		subject = NewFollower(server.URL, replica, &FollowerOptions{
+			Index:    index,
+			Interval: 10e6,
+			OnError:  func(err error) { errs <- err },
+		})
+
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			subject.Run(stop)
+		}()
+
+		Expect(writer.Put([]byte("foo"), []byte("v3"))).To(Succeed())
+		Expect(writer.Flush()).To(Succeed())
+		Eventually(func() []string { return readValues(index, replica, "foo") }).Should(Equal([]string{"v1", "v3"}))
+
+		close(stop)
+		Eventually(done).Should(BeClosed())
+		Expect(errs).To(BeEmpty())
+	})
+
+})
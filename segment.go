@@ -0,0 +1,325 @@
+package ccdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const manifestFileName = "MANIFEST"
+
+// SegmentOptions can be used to configure segmented writers
+type SegmentOptions struct {
+	// MaxSize rotates to a new segment once the current
+	// segment reaches the given size. Default: 64MiB
+	MaxSize int64
+
+	// MaxAge rotates to a new segment once the current
+	// segment reaches the given age. Default: unlimited
+	MaxAge time.Duration
+
+	// Log options are applied to each individual segment.
+	Log *LogOptions
+}
+
+func (o *SegmentOptions) norm() *SegmentOptions {
+	var opt SegmentOptions
+	if o != nil {
+		opt = *o
+	}
+	if opt.MaxSize <= 0 {
+		opt.MaxSize = 64 * 1024 * 1024
+	}
+	return &opt
+}
+
+// --------------------------------------------------------------------
+
+type manifest struct {
+	Segments []segmentInfo `json:"segments"`
+	Next     int           `json:"next"`
+}
+
+type segmentInfo struct {
+	ID      uint32    `json:"id"`
+	Log     string    `json:"log"`
+	Index   string    `json:"index"`
+	Created time.Time `json:"created"`
+	Sealed  bool      `json:"sealed"`
+	Indexed bool      `json:"indexed,omitempty"` // unsealed, but closed with an index
+}
+
+func readManifest(dir string) (*manifest, error) {
+	file, err := os.Open(filepath.Join(dir, manifestFileName))
+	if os.IsNotExist(err) {
+		return &manifest{Next: 1}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m := new(manifest)
+	if err := json.NewDecoder(file).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeTo atomically replaces the manifest in dir
+func (m *manifest) writeTo(dir string) error {
+	return writeJSONFile(filepath.Join(dir, manifestFileName), m)
+}
+
+// --------------------------------------------------------------------
+
+// SegmentedWriter appends to a series of log segments within a directory,
+// rotating to a new segment when size or age thresholds are reached.
+// Segments and their indexes are recorded in a manifest file.
+type SegmentedWriter struct {
+	dir string
+	opt *SegmentOptions
+
+	mutex    sync.RWMutex
+	manifest *manifest
+	current  *LogWriter
+}
+
+// OpenSegmented opens a segmented log directory for writing, creating it if
+// necessary. Writes are appended to the most recent, unsealed segment, which
+// is hidden from readers until the writer is closed again.
+func OpenSegmented(dir string, opt *SegmentOptions) (*SegmentedWriter, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &SegmentedWriter{dir: dir, opt: opt.norm(), manifest: m}
+	if n := len(m.Segments); n != 0 && !m.Segments[n-1].Sealed {
+		seg := &m.Segments[n-1]
+		seg.Indexed = false
+		if err := m.writeTo(dir); err != nil {
+			return nil, err
+		}
+
+		// metadata is retained from segment creation
+		var lopt LogOptions
+		if w.opt.Log != nil {
+			lopt = *w.opt.Log
+		}
+		lopt.Metadata = nil
+
+		if w.current, err = AppendLogWithOptions(filepath.Join(dir, seg.Log), &lopt); err != nil {
+			return nil, err
+		}
+	} else if err := w.create(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Put inserts a new key/value pair, rotating segments if necessary
+func (w *SegmentedWriter) Put(key, val []byte) error {
+	w.mutex.RLock()
+	if w.rotationDue() {
+		w.mutex.RUnlock()
+		w.mutex.Lock()
+		if w.rotationDue() {
+			if err := w.rotate(); err != nil {
+				w.mutex.Unlock()
+				return err
+			}
+		}
+		w.mutex.Unlock()
+		w.mutex.RLock()
+	}
+	defer w.mutex.RUnlock()
+
+	if w.current == nil {
+		return errClosed
+	}
+	return w.current.Put(key, val)
+}
+
+// Rotate seals the current segment and starts a new one
+func (w *SegmentedWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.current == nil {
+		return errClosed
+	}
+	return w.rotate()
+}
+
+// Flush flushes the current segment
+func (w *SegmentedWriter) Flush() error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if w.current == nil {
+		return errClosed
+	}
+	return w.current.Flush()
+}
+
+// Close writes an index for the current segment and closes the writer.
+// The segment remains unsealed and is appended to when reopened.
+func (w *SegmentedWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.current == nil {
+		return nil
+	}
+	if err := w.closeCurrent(); err != nil {
+		return err
+	}
+
+	w.manifest.Segments[len(w.manifest.Segments)-1].Indexed = true
+	return w.manifest.writeTo(w.dir)
+}
+
+// rotationDue returns true if the current segment
+// should be rotated, must be called with a lock held
+func (w *SegmentedWriter) rotationDue() bool {
+	if w.current == nil {
+		return false
+	}
+	if w.current.Size() >= w.opt.MaxSize {
+		return true
+	}
+	if w.opt.MaxAge > 0 {
+		seg := w.manifest.Segments[len(w.manifest.Segments)-1]
+		return time.Since(seg.Created) >= w.opt.MaxAge
+	}
+	return false
+}
+
+// rotate seals the current and starts a new segment, must be called with the write lock held
+func (w *SegmentedWriter) rotate() error {
+	if err := w.seal(); err != nil {
+		return err
+	}
+	return w.create()
+}
+
+// seal writes an index for the current segment, closes
+// it and updates the manifest, must be called with the write lock held
+func (w *SegmentedWriter) seal() error {
+	if err := w.closeCurrent(); err != nil {
+		return err
+	}
+
+	w.manifest.Segments[len(w.manifest.Segments)-1].Sealed = true
+	return w.manifest.writeTo(w.dir)
+}
+
+// closeCurrent writes an index for the current segment and
+// closes it, must be called with the write lock held
+func (w *SegmentedWriter) closeCurrent() error {
+	seg := w.manifest.Segments[len(w.manifest.Segments)-1]
+	err := w.current.WriteIndex(filepath.Join(w.dir, seg.Index))
+	if e := w.current.Close(); e != nil && err == nil {
+		err = e
+	}
+	w.current = nil
+	return err
+}
+
+// create starts a new segment, must be called with the write lock held
+func (w *SegmentedWriter) create() error {
+	seq := w.manifest.Next
+	seg := segmentInfo{
+		Log:     fmt.Sprintf("%08d.ccl", seq),
+		Index:   fmt.Sprintf("%08d.cci", seq),
+		Created: time.Now().UTC(),
+	}
+
+	writer, err := CreateLogWithOptions(filepath.Join(w.dir, seg.Log), w.opt.Log)
+	if err != nil {
+		return err
+	}
+	seg.ID = writer.header.id
+
+	w.manifest.Segments = append(w.manifest.Segments, seg)
+	w.manifest.Next++
+	if err := w.manifest.writeTo(w.dir); err != nil {
+		writer.Close()
+		return err
+	}
+
+	w.current = writer
+	return nil
+}
+
+// --------------------------------------------------------------------
+
+// SegmentedDB is a read-only abstraction of the segments of a segmented
+// log directory. It includes all sealed segments and the most recent
+// segment, as of the last time its writer was closed. Segments which are
+// open for writing are excluded.
+type SegmentedDB struct {
+	dbs []*DB
+	obs Observer
+}
+
+// OpenSegmentedDB opens all sealed and closed segments of a directory for reading
+func OpenSegmentedDB(dir string) (*SegmentedDB, error) {
+	return OpenSegmentedDBWithOptions(dir, nil)
+}
+
+// OpenSegmentedDBWithOptions opens all sealed and closed segments using
+// custom options, i.e. to decrypt encrypted segments
+func OpenSegmentedDBWithOptions(dir string, opt *ReadOptions) (*SegmentedDB, error) {
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sdb := new(SegmentedDB)
+	if opt != nil {
+		sdb.obs = opt.Observer
+	}
+	for _, seg := range m.Segments {
+		if !seg.Sealed && !seg.Indexed {
+			continue
+		}
+
+		db, err := OpenWithOptions(filepath.Join(dir, seg.Index), filepath.Join(dir, seg.Log), opt)
+		if err != nil {
+			sdb.Close()
+			return nil, err
+		}
+		sdb.dbs = append(sdb.dbs, db)
+
+		if db.log.header.id != seg.ID {
+			sdb.Close()
+			return nil, errBadFileID
+		}
+	}
+	return sdb, nil
+}
+
+// Close closes all segments
+func (s *SegmentedDB) Close() error {
+	var err error
+	for _, db := range s.dbs {
+		if e := db.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Get retrieves a key and returns an iterator
+// over the values from all segments, oldest first
+func (s *SegmentedDB) Get(key []byte) (*Iterator, error) {
+	return newIterator(key, s.dbs, time.Now(), s.obs)
+}
@@ -0,0 +1,146 @@
+package ccdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SegmentedWriter", func() {
+	var subject *SegmentedWriter
+	var dir string
+
+	var readSegments = func(key string) []string {
+		db, err := OpenSegmentedDB(dir)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		return getValues(db, key)
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+
+		var err error
+		subject, err = OpenSegmented(dir, &SegmentOptions{MaxSize: 200})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subject.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should rotate by size", func() {
+		for i := 0; i < 10; i++ {
+			Expect(subject.Put([]byte("key"), []byte(fmt.Sprintf("value.%02d", i)))).To(Succeed())
+		}
+		Expect(subject.Close()).To(Succeed())
+
+		m, err := readManifest(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Next).To(Equal(3))
+		Expect(m.Segments).To(HaveLen(2))
+		Expect(m.Segments[0].Log).To(Equal("00000001.ccl"))
+		Expect(m.Segments[0].Index).To(Equal("00000001.cci"))
+		Expect(m.Segments[0].Sealed).To(BeTrue())
+		Expect(m.Segments[1].Log).To(Equal("00000002.ccl"))
+		Expect(m.Segments[1].Sealed).To(BeFalse())
+		Expect(m.Segments[1].Indexed).To(BeTrue())
+
+		Expect(readSegments("key")).To(Equal([]string{
+			"value.00", "value.01", "value.02", "value.03", "value.04",
+			"value.05", "value.06", "value.07", "value.08", "value.09",
+		}))
+		Expect(readSegments("missing")).To(BeEmpty())
+	})
+
+	It("should rotate by age", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		var err error
+		subject, err = OpenSegmented(dir, &SegmentOptions{MaxAge: time.Nanosecond})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(subject.Put([]byte("key"), []byte("value.00"))).To(Succeed())
+		Expect(subject.Put([]byte("key"), []byte("value.01"))).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		m, err := readManifest(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Segments).To(HaveLen(3))
+		Expect(readSegments("key")).To(Equal([]string{"value.00", "value.01"}))
+	})
+
+	It("should only expose sealed and closed segments", func() {
+		Expect(subject.Put([]byte("key"), []byte("value.00"))).To(Succeed())
+		Expect(subject.Flush()).To(Succeed())
+		Expect(readSegments("key")).To(BeEmpty())
+
+		Expect(subject.Rotate()).To(Succeed())
+		Expect(subject.Put([]byte("key"), []byte("value.01"))).To(Succeed())
+		Expect(readSegments("key")).To(Equal([]string{"value.00"}))
+
+		Expect(subject.Close()).To(Succeed())
+		Expect(readSegments("key")).To(Equal([]string{"value.00", "value.01"}))
+
+		var err error
+		subject, err = OpenSegmented(dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readSegments("key")).To(Equal([]string{"value.00"}))
+	})
+
+	It("should encrypt segments", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		keys := StaticKeys{1: bytes.Repeat([]byte{1}, 16)}
+		var err error
+		subject, err = OpenSegmented(dir, &SegmentOptions{Log: &LogOptions{Keys: keys, KeyID: 1}})
+		Expect(err).To(Equal(ErrNotEncrypted))
+
+		Expect(os.RemoveAll(dir)).To(Succeed())
+		subject, err = OpenSegmented(dir, &SegmentOptions{Log: &LogOptions{Keys: keys, KeyID: 1}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key"), []byte("secret"))).To(Succeed())
+		Expect(subject.Rotate()).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		subject, err = OpenSegmented(dir, &SegmentOptions{Log: &LogOptions{Keys: keys}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key"), []byte("secret2"))).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		db, err := OpenSegmentedDBWithOptions(dir, &ReadOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		Expect(getValues(db, "key")).To(Equal([]string{"secret", "secret2"}))
+	})
+
+	It("should reopen", func() {
+		Expect(subject.Put([]byte("key"), []byte("value.00"))).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+		Expect(subject.Put([]byte("key"), []byte("value.01"))).To(Equal(errClosed))
+
+		var err error
+		subject, err = OpenSegmented(dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key"), []byte("value.01"))).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+
+		m, err := readManifest(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Segments).To(HaveLen(1))
+		Expect(m.Next).To(Equal(2))
+		Expect(readSegments("key")).To(Equal([]string{"value.00", "value.01"}))
+
+		_, err = os.Stat(filepath.Join(dir, "00000001.cci"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,214 @@
+package ccdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+)
+
+const shardsFileName = "SHARDS"
+
+type shardDescriptor struct {
+	Shards int      `json:"shards"`
+	IDs    []uint32 `json:"ids"`
+}
+
+func readShardDescriptor(dir string) (*shardDescriptor, error) {
+	file, err := os.Open(filepath.Join(dir, shardsFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	desc := new(shardDescriptor)
+	if err := json.NewDecoder(file).Decode(desc); err != nil {
+		return nil, err
+	}
+	if desc.Shards < 1 || len(desc.IDs) != desc.Shards {
+		return nil, errShardMismatch
+	}
+	return desc, nil
+}
+
+func (d *shardDescriptor) writeTo(dir string) error {
+	return writeJSONFile(filepath.Join(dir, shardsFileName), d)
+}
+
+func shardLogName(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard-%04d.ccl", n))
+}
+
+func shardIndexName(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard-%04d.cci", n))
+}
+
+// shardOf returns the shard number for a key
+func shardOf(key []byte, nshards int) int {
+	hash := fnv.New32a()
+	hash.Write(key)
+	return int(hash.Sum32() % uint32(nshards))
+}
+
+// --------------------------------------------------------------------
+
+// ShardedWriter distributes writes across multiple log files
+// within a directory, routing each key by its hash
+type ShardedWriter struct {
+	dir    string
+	shards []*LogWriter
+}
+
+// CreateSharded creates a new set of n log shards within dir. The
+// FileID option is ignored, each shard is assigned a random ID.
+func CreateSharded(dir string, nshards int, opt *LogOptions) (*ShardedWriter, error) {
+	if nshards < 1 {
+		return nil, ErrInvalidShardCount
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	var sopt LogOptions
+	if opt != nil {
+		sopt = *opt
+	}
+	sopt.FileID = 0
+
+	w := &ShardedWriter{dir: dir}
+	desc := &shardDescriptor{Shards: nshards}
+	for i := 0; i < nshards; i++ {
+		shard, err := CreateLogWithOptions(shardLogName(dir, i), &sopt)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		w.shards = append(w.shards, shard)
+		desc.IDs = append(desc.IDs, shard.header.id)
+	}
+
+	if err := desc.writeTo(dir); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// AppendSharded opens an existing set of log shards for appending
+func AppendSharded(dir string, opt *LogOptions) (*ShardedWriter, error) {
+	desc, err := readShardDescriptor(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ShardedWriter{dir: dir}
+	for i, id := range desc.IDs {
+		var sopt LogOptions
+		if opt != nil {
+			sopt = *opt
+		}
+		sopt.FileID = id
+
+		shard, err := AppendLogWithOptions(shardLogName(dir, i), &sopt)
+		if err == ErrFileIDMismatch {
+			err = errShardMismatch
+		}
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		w.shards = append(w.shards, shard)
+	}
+	return w, nil
+}
+
+// Put inserts a new key/value pair into the responsible shard
+func (w *ShardedWriter) Put(key, val []byte) error {
+	return w.shards[shardOf(key, len(w.shards))].Put(key, val)
+}
+
+// Flush flushes all shards
+func (w *ShardedWriter) Flush() error {
+	var err error
+	for _, shard := range w.shards {
+		if e := shard.Flush(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// WriteIndex writes an index for each shard
+func (w *ShardedWriter) WriteIndex() error {
+	for i, shard := range w.shards {
+		if err := shard.WriteIndex(shardIndexName(w.dir, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes all shards
+func (w *ShardedWriter) Close() error {
+	var err error
+	for _, shard := range w.shards {
+		if e := shard.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// --------------------------------------------------------------------
+
+// ShardedDB is a read-only abstraction of a set of shards
+type ShardedDB struct {
+	shards []*DB
+}
+
+// OpenShardedDB opens a set of indexed shards for reading
+func OpenShardedDB(dir string) (*ShardedDB, error) {
+	return OpenShardedDBWithOptions(dir, nil)
+}
+
+// OpenShardedDBWithOptions opens a set of indexed shards using custom
+// options, i.e. to decrypt encrypted shards
+func OpenShardedDBWithOptions(dir string, opt *ReadOptions) (*ShardedDB, error) {
+	desc, err := readShardDescriptor(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sdb := new(ShardedDB)
+	for i, id := range desc.IDs {
+		db, err := OpenWithOptions(shardIndexName(dir, i), shardLogName(dir, i), opt)
+		if err != nil {
+			sdb.Close()
+			return nil, err
+		}
+		sdb.shards = append(sdb.shards, db)
+
+		if db.log.header.id != id || db.index.header.id != id {
+			sdb.Close()
+			return nil, errShardMismatch
+		}
+	}
+	return sdb, nil
+}
+
+// Close closes all shards
+func (s *ShardedDB) Close() error {
+	var err error
+	for _, db := range s.shards {
+		if e := db.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Get retrieves a key from the responsible shard and returns a value iterator
+func (s *ShardedDB) Get(key []byte) (*Iterator, error) {
+	return s.shards[shardOf(key, len(s.shards))].Get(key)
+}
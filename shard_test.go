@@ -0,0 +1,128 @@
+package ccdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ShardedWriter", func() {
+	var subject *ShardedWriter
+	var dir string
+
+	BeforeEach(func() {
+		dir = mkTemp()
+
+		var err error
+		subject, err = CreateSharded(dir, 4, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 100; i++ {
+			key := []byte(fmt.Sprintf("key.%04d", i))
+			Expect(subject.Put(key, []byte(fmt.Sprintf("val.%04d.00", i)))).To(Succeed())
+			Expect(subject.Put(key, []byte(fmt.Sprintf("val.%04d.01", i)))).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		subject.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should validate", func() {
+		_, err := CreateSharded(dir, 0, nil)
+		Expect(err).To(Equal(ErrInvalidShardCount))
+	})
+
+	It("should route by key hash", func() {
+		Expect(shardOf([]byte("key.0000"), 4)).To(Equal(2))
+		Expect(shardOf([]byte("key.0001"), 4)).To(Equal(1))
+
+		for _, shard := range subject.shards {
+			Expect(shard.Size()).To(BeNumerically(">", 800))
+		}
+	})
+
+	It("should write indexes and read", func() {
+		Expect(subject.WriteIndex()).To(Succeed())
+
+		db, err := OpenShardedDB(dir)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(db.shards).To(HaveLen(4))
+
+		for i := 0; i < 100; i++ {
+			iter, err := db.Get([]byte(fmt.Sprintf("key.%04d", i)))
+			Expect(err).NotTo(HaveOccurred())
+			vals, err := iter.All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vals).To(Equal([][]byte{
+				[]byte(fmt.Sprintf("val.%04d.00", i)),
+				[]byte(fmt.Sprintf("val.%04d.01", i)),
+			}))
+		}
+	})
+
+	It("should reopen for appending", func() {
+		Expect(subject.Close()).To(Succeed())
+
+		var err error
+		subject, err = AppendSharded(dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.shards).To(HaveLen(4))
+		Expect(subject.Put([]byte("key.0000"), []byte("val.0000.02"))).To(Succeed())
+		Expect(subject.WriteIndex()).To(Succeed())
+
+		db, err := OpenShardedDB(dir)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		iter, err := db.Get([]byte("key.0000"))
+		Expect(err).NotTo(HaveOccurred())
+		vals, err := iter.All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vals).To(HaveLen(3))
+	})
+
+	It("should encrypt shards", func() {
+		Expect(subject.Close()).To(Succeed())
+		Expect(os.RemoveAll(dir)).To(Succeed())
+
+		keys := StaticKeys{1: bytes.Repeat([]byte{1}, 16)}
+		var err error
+		subject, err = CreateSharded(dir, 2, &LogOptions{Keys: keys, KeyID: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Put([]byte("key"), []byte("secret"))).To(Succeed())
+		Expect(subject.WriteIndex()).To(Succeed())
+
+		db, err := OpenShardedDB(dir)
+		Expect(err).NotTo(HaveOccurred())
+		iter, err := db.Get([]byte("key"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = iter.All()
+		Expect(err).To(Equal(ErrMissingKey))
+		Expect(db.Close()).To(Succeed())
+
+		db, err = OpenShardedDBWithOptions(dir, &ReadOptions{Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(getValues(db, "key")).To(Equal([]string{"secret"}))
+	})
+
+	It("should detect mismatched shards", func() {
+		Expect(subject.WriteIndex()).To(Succeed())
+		Expect(subject.Close()).To(Succeed())
+		Expect(os.Rename(shardLogName(dir, 0), shardLogName(dir, 9))).To(Succeed())
+		Expect(os.Rename(shardLogName(dir, 1), shardLogName(dir, 0))).To(Succeed())
+
+		_, err := AppendSharded(dir, nil)
+		Expect(err).To(Equal(errShardMismatch))
+
+		_, err = OpenShardedDB(dir)
+		Expect(err).To(Equal(errHeaderDifferent))
+	})
+
+})
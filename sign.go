@@ -0,0 +1,124 @@
+package ccdb
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// signatureContext separates ccdb signatures from other uses of the same key
+const signatureContext = "ccdb signature v1\n"
+
+// SignatureFileName returns the path of the signature file of a log
+func SignatureFileName(logFileName string) string {
+	return logFileName + ".sig"
+}
+
+type signature struct {
+	Algorithm string `json:"algorithm"`
+	Log       string `json:"log_sha256"`
+	Index     string `json:"index_sha256"`
+	Signature []byte `json:"signature"`
+}
+
+// Sign signs a sealed log and its index with an Ed25519 key. The signature
+// covers the SHA-256 digests of both files and is stored next to the log,
+// see SignatureFileName. Logs must not be modified after signing.
+func Sign(indexFileName, logFileName string, key ed25519.PrivateKey) error {
+	index, err := OpenIndex(indexFileName)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	log, err := OpenLog(logFileName)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	if index.header.id != log.header.id {
+		return errHeaderDifferent
+	} else if index.header.pos != log.header.pos {
+		return errNotSealed
+	}
+
+	ldigest, idigest, err := fileDigests(index.fileReader, log.fileReader)
+	if err != nil {
+		return err
+	}
+
+	return writeJSONFile(SignatureFileName(logFileName), &signature{
+		Algorithm: "ed25519",
+		Log:       hex.EncodeToString(ldigest),
+		Index:     hex.EncodeToString(idigest),
+		Signature: ed25519.Sign(key, signedMessage(ldigest, idigest)),
+	})
+}
+
+// OpenVerified opens a DB for read-only access after verifying its signature
+// against a trusted public key. See ReadOptions.PublicKey.
+func OpenVerified(indexFileName, logFileName string, pub ed25519.PublicKey) (*DB, error) {
+	return OpenWithOptions(indexFileName, logFileName, &ReadOptions{PublicKey: pub})
+}
+
+// verifySignature verifies the signature of an opened log and index
+func verifySignature(index *IndexReader, log *LogReader, pub ed25519.PublicKey) error {
+	file, err := os.Open(SignatureFileName(log.file.Name()))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var sig signature
+	if err := json.NewDecoder(file).Decode(&sig); err != nil {
+		return err
+	} else if sig.Algorithm != "ed25519" {
+		return ErrInvalidSignature
+	}
+
+	ldigest, idigest, err := fileDigests(index.fileReader, log.fileReader)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, signedMessage(ldigest, idigest), sig.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// fileDigests returns the SHA-256 digests of the complete log and index files
+func fileDigests(index, log *fileReader) ([]byte, []byte, error) {
+	ldigest, err := fileDigest(log)
+	if err != nil {
+		return nil, nil, err
+	}
+	idigest, err := fileDigest(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ldigest, idigest, nil
+}
+
+func fileDigest(r *fileReader) ([]byte, error) {
+	stat, err := r.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(r.file, 0, stat.Size())); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+func signedMessage(ldigest, idigest []byte) []byte {
+	msg := make([]byte, 0, len(signatureContext)+len(ldigest)+len(idigest))
+	msg = append(msg, signatureContext...)
+	msg = append(msg, ldigest...)
+	return append(msg, idigest...)
+}
@@ -0,0 +1,92 @@
+package ccdb
+
+import (
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sign", func() {
+	var dir, iname, lname string
+	var pub ed25519.PublicKey
+	var key ed25519.PrivateKey
+
+	var tamper = func(fname string, offset int) {
+		data, err := ioutil.ReadFile(fname)
+		Expect(err).NotTo(HaveOccurred())
+		data[offset]++
+		Expect(ioutil.WriteFile(fname, data, 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		pub, key, err = ed25519.GenerateKey(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		dir = mkTemp()
+		lname = filepath.Join(dir, "data.ccl")
+		iname = filepath.Join(dir, "data.cci")
+
+		writer, err := CreateLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(writer.WriteIndex(iname)).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		Expect(Sign(iname, lname, key)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should open verified databases", func() {
+		db, err := OpenVerified(iname, lname, pub)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		iter, err := db.Get([]byte("key"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.All()).To(Equal([][]byte{[]byte("value")}))
+	})
+
+	It("should reject unknown keys", func() {
+		other, _, err := ed25519.GenerateKey(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = OpenVerified(iname, lname, other)
+		Expect(err).To(Equal(ErrInvalidSignature))
+	})
+
+	It("should reject modified logs", func() {
+		tamper(lname, fileHeaderLen+6)
+		_, err := OpenVerified(iname, lname, pub)
+		Expect(err).To(Equal(ErrInvalidSignature))
+	})
+
+	It("should reject modified indexes", func() {
+		tamper(iname, fileHeaderLen+3*12)
+		_, err := OpenVerified(iname, lname, pub)
+		Expect(err).To(Equal(ErrInvalidSignature))
+	})
+
+	It("should require signatures", func() {
+		Expect(os.Remove(SignatureFileName(lname))).To(Succeed())
+		_, err := OpenVerified(iname, lname, pub)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should only sign sealed logs", func() {
+		writer, err := AppendLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		Expect(Sign(iname, lname, key)).To(Equal(errNotSealed))
+	})
+
+})
@@ -0,0 +1,206 @@
+package ccdb
+
+import (
+	"bytes"
+	"math/bits"
+	"sort"
+)
+
+// Histogram is a power-of-two histogram of sizes
+type Histogram struct {
+	Count int64 `json:"count"`
+	Sum   int64 `json:"sum"`
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+
+	// Buckets counts sizes by their bit length, i.e. Buckets[n]
+	// counts sizes within [2^(n-1), 2^n).
+	Buckets []int64 `json:"buckets"`
+}
+
+// Add adds a size to the histogram
+func (h *Histogram) Add(size int64) {
+	if h.Count == 0 || size < h.Min {
+		h.Min = size
+	}
+	if size > h.Max {
+		h.Max = size
+	}
+	h.Count++
+	h.Sum += size
+
+	n := bits.Len64(uint64(size))
+	for len(h.Buckets) <= n {
+		h.Buckets = append(h.Buckets, 0)
+	}
+	h.Buckets[n]++
+}
+
+// Mean returns the mean size
+func (h *Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return float64(h.Sum) / float64(h.Count)
+}
+
+// --------------------------------------------------------------------
+
+// LogStats contains log statistics
+type LogStats struct {
+	Entries    int64     `json:"entries"`
+	Bytes      int64     `json:"bytes"` // bytes covered by entries
+	KeySizes   Histogram `json:"key_sizes"`
+	ValueSizes Histogram `json:"value_sizes"`
+}
+
+// Stats returns statistics. It scans the entire log, reading the key of
+// every entry, which is costly for large logs.
+func (r *LogReader) Stats() (*LogStats, error) {
+	stats := &LogStats{Bytes: r.header.pos - r.header.dataOffset()}
+
+	iter := r.Iterator()
+	iter.skipVal = true
+	for iter.Next() {
+		stats.Entries++
+		stats.KeySizes.Add(int64(len(iter.Entry().Key)))
+		stats.ValueSizes.Add(iter.vlen)
+	}
+	return stats, iter.Error()
+}
+
+// --------------------------------------------------------------------
+
+// IndexStats contains index statistics
+type IndexStats struct {
+	Entries           int64   `json:"entries"`            // number of indexed entries
+	DistinctChecksums int64   `json:"distinct_checksums"` // number of distinct checksums
+	SharedChecksums   int64   `json:"shared_checksums"`   // number of checksums shared by multiple entries, by repeated keys or collisions
+	Collisions        int64   `json:"collisions"`         // number of checksums shared by different keys, only set by DB.Stats
+	Slots             int64   `json:"slots"`              // total number of slots
+	Fill              float64 `json:"fill"`               // ratio of used slots
+	MaxProbe          int     `json:"max_probe"`          // maximum number of probes to find an entry
+	AvgProbe          float64 `json:"avg_probe"`          // average number of probes to find an entry
+	BytesCovered      int64   `json:"bytes_covered"`      // log bytes covered by the index
+
+	Buckets []BucketStats `json:"buckets"`
+}
+
+// BucketStats contains per-bucket index statistics
+type BucketStats struct {
+	Slots    int `json:"slots"`
+	Used     int `json:"used"`
+	MaxProbe int `json:"max_probe"`
+}
+
+// Stats reads the index and returns statistics. Keys are not resolved
+// against the log, so checksum collisions cannot be told apart from
+// repeated keys and Collisions is left blank.
+func (i *IndexReader) Stats() (*IndexStats, error) {
+	return i.stats(nil)
+}
+
+// stats reads the index and returns statistics, calling shared
+// with the used slots of each checksum shared by multiple entries.
+func (i *IndexReader) stats(shared func([]slot) error) (*IndexStats, error) {
+	stats := &IndexStats{
+		BytesCovered: i.header.pos - i.header.dataOffset(),
+		Buckets:      make([]BucketStats, numBuckets),
+	}
+
+	var probes int64
+	tbuf := make([]byte, 12)
+	var used []slot
+	for n := range stats.Buckets {
+		offset, nslots, err := i.seekBucket(n, tbuf)
+		if err != nil {
+			return nil, err
+		}
+
+		slots, err := i.readSlots(offset, nslots)
+		if err != nil {
+			return nil, err
+		}
+
+		bucket := &stats.Buckets[n]
+		bucket.Slots = nslots
+		used = used[:0]
+		for pos, s := range slots {
+			if s.lpos == 0 {
+				continue
+			}
+			bucket.Used++
+			used = append(used, s)
+
+			probe := (pos-s.cksum.Slot()%nslots+nslots)%nslots + 1
+			if probe > bucket.MaxProbe {
+				bucket.MaxProbe = probe
+			}
+			probes += int64(probe)
+		}
+
+		// a checksum always maps to the same bucket, so shared
+		// checksums can be counted one bucket at a time
+		sort.Slice(used, func(a, b int) bool { return used[a].cksum < used[b].cksum })
+		for j := 0; j < len(used); {
+			k := j + 1
+			for k < len(used) && used[k].cksum == used[j].cksum {
+				k++
+			}
+			stats.DistinctChecksums++
+			if k-j > 1 {
+				stats.SharedChecksums++
+				if shared != nil {
+					if err := shared(used[j:k]); err != nil {
+						return nil, err
+					}
+				}
+			}
+			j = k
+		}
+
+		stats.Entries += int64(bucket.Used)
+		stats.Slots += int64(bucket.Slots)
+		if bucket.MaxProbe > stats.MaxProbe {
+			stats.MaxProbe = bucket.MaxProbe
+		}
+	}
+
+	if stats.Slots != 0 {
+		stats.Fill = float64(stats.Entries) / float64(stats.Slots)
+	}
+	if stats.Entries != 0 {
+		stats.AvgProbe = float64(probes) / float64(stats.Entries)
+	}
+	return stats, nil
+}
+
+// Stats reads the index and returns statistics. Entries sharing a
+// checksum are resolved against the log to count Collisions, which
+// reads the keys of those entries.
+func (db *DB) Stats() (*IndexStats, error) {
+	var collisions int64
+	stats, err := db.index.stats(func(slots []slot) error {
+		first, _, err := db.log.entryAt(slots[0].lpos)
+		if err != nil {
+			return err
+		}
+		for _, s := range slots[1:] {
+			ent, _, err := db.log.entryAt(s.lpos)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(ent.Key, first.Key) {
+				collisions++
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Collisions = collisions
+	return stats, nil
+}
@@ -0,0 +1,118 @@
+package ccdb
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Histogram", func() {
+
+	It("should add sizes", func() {
+		h := new(Histogram)
+		Expect(h.Mean()).To(Equal(0.0))
+
+		for _, n := range []int64{1, 2, 3, 4, 8, 100} {
+			h.Add(n)
+		}
+		Expect(*h).To(Equal(Histogram{
+			Count:   6,
+			Sum:     118,
+			Min:     1,
+			Max:     100,
+			Buckets: []int64{0, 1, 2, 1, 1, 0, 0, 1},
+		}))
+		Expect(h.Mean()).To(BeNumerically("~", 19.67, 0.01))
+	})
+
+})
+
+var _ = Describe("Stats", func() {
+	var dir, lname, iname string
+
+	BeforeEach(func() {
+		var err error
+		dir = mkTemp()
+		lname, iname, err = writeTestLogAndIndex(dir, 500)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should calculate log stats", func() {
+		reader, err := OpenLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		stats, err := reader.Stats()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Entries).To(Equal(int64(1390)))
+		Expect(stats.Bytes).To(Equal(int64(29190)))
+		Expect(stats.KeySizes.Count).To(Equal(int64(1390)))
+		Expect(stats.KeySizes.Mean()).To(Equal(8.0))
+		Expect(stats.ValueSizes.Max).To(Equal(int64(11)))
+		Expect(stats.ValueSizes.Buckets).To(Equal([]int64{0, 0, 0, 0, 1390}))
+	})
+
+	It("should calculate index stats", func() {
+		reader, err := OpenIndex(iname)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+
+		stats, err := reader.Stats()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Entries).To(Equal(int64(1390)))
+		Expect(stats.DistinctChecksums).To(Equal(int64(500)))
+		Expect(stats.SharedChecksums).To(Equal(int64(389)))
+		Expect(stats.Slots).To(Equal(int64(2780)))
+		Expect(stats.Fill).To(Equal(0.5))
+		Expect(stats.BytesCovered).To(Equal(int64(29190)))
+		Expect(stats.MaxProbe).To(BeNumerically(">=", 5))
+		Expect(stats.AvgProbe).To(BeNumerically(">", 1))
+		Expect(stats.Buckets).To(HaveLen(256))
+
+		var used int
+		for _, b := range stats.Buckets {
+			Expect(b.Slots).To(Equal(b.Used * 2))
+			used += b.Used
+		}
+		Expect(used).To(Equal(1390))
+	})
+
+	It("should resolve collisions", func() {
+		db, err := Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		stats, err := db.Stats()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.SharedChecksums).To(Equal(int64(389)))
+		Expect(stats.Collisions).To(BeZero())
+
+		clname, ciname, err := writeTestWithCollisions(dir, 2)
+		Expect(err).NotTo(HaveOccurred())
+
+		index, err := OpenIndex(ciname)
+		Expect(err).NotTo(HaveOccurred())
+		defer index.Close()
+
+		stats, err = index.Stats()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.DistinctChecksums).To(Equal(int64(1)))
+		Expect(stats.SharedChecksums).To(Equal(int64(1)))
+		Expect(stats.Collisions).To(BeZero())
+
+		cdb, err := Open(ciname, clname)
+		Expect(err).NotTo(HaveOccurred())
+		defer cdb.Close()
+
+		stats, err = cdb.Stats()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.Entries).To(Equal(int64(4)))
+		Expect(stats.Collisions).To(Equal(int64(1)))
+	})
+
+})
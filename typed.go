@@ -0,0 +1,225 @@
+//go:build go1.18
+// +build go1.18
+
+package ccdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"time"
+	"unsafe"
+)
+
+// Codec encodes and decodes keys or values of type T
+type Codec[T any] interface {
+	Encode(T) ([]byte, error)
+	Decode([]byte) (T, error)
+}
+
+// BytesCodec stores byte slices as they are
+type BytesCodec struct{}
+
+// Encode implements Codec
+func (BytesCodec) Encode(v []byte) ([]byte, error) { return v, nil }
+
+// Decode implements Codec
+func (BytesCodec) Decode(b []byte) ([]byte, error) { return b, nil }
+
+// StringCodec stores strings as they are
+type StringCodec struct{}
+
+// Encode implements Codec
+func (StringCodec) Encode(v string) ([]byte, error) { return []byte(v), nil }
+
+// Decode implements Codec
+func (StringCodec) Decode(b []byte) (string, error) { return string(b), nil }
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// IntCodec stores integers as fixed-width, big-endian values, with the
+// sign bit of signed integers flipped. The encoding preserves the order
+// of values, i.e. encoded keys sort like the integers they represent.
+type IntCodec[T integer] struct{}
+
+// Encode implements Codec
+func (IntCodec[T]) Encode(v T) ([]byte, error) {
+	size := int(unsafe.Sizeof(v))
+	u := uint64(v)
+	if isSigned[T]() {
+		u ^= 1 << uint(8*size-1)
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], u)
+	return buf[8-size:], nil
+}
+
+// Decode implements Codec
+func (IntCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	size := int(unsafe.Sizeof(v))
+	if len(b) != size {
+		return v, errCodecSize
+	}
+
+	var buf [8]byte
+	copy(buf[8-size:], b)
+	u := binary.BigEndian.Uint64(buf[:])
+	if isSigned[T]() {
+		u ^= 1 << uint(8*size-1)
+	}
+	return T(u), nil
+}
+
+func isSigned[T integer]() bool {
+	var zero T
+	return zero-1 < 0
+}
+
+// JSONCodec stores values as JSON
+type JSONCodec[T any] struct{}
+
+// Encode implements Codec
+func (JSONCodec[T]) Encode(v T) ([]byte, error) { return json.Marshal(v) }
+
+// Decode implements Codec
+func (JSONCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// GobCodec stores values using encoding/gob. Every value is encoded
+// separately and includes its type information.
+type GobCodec[T any] struct{}
+
+// Encode implements Codec
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements Codec
+func (GobCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v)
+	return v, err
+}
+
+// --------------------------------------------------------------------
+
+// TypedWriter wraps a LogWriter and encodes keys and values using codecs
+type TypedWriter[K, V any] struct {
+	w    *LogWriter
+	keys Codec[K]
+	vals Codec[V]
+}
+
+// NewTypedWriter wraps w
+func NewTypedWriter[K, V any](w *LogWriter, keys Codec[K], vals Codec[V]) *TypedWriter[K, V] {
+	return &TypedWriter[K, V]{w: w, keys: keys, vals: vals}
+}
+
+// Writer returns the underlying log writer
+func (w *TypedWriter[K, V]) Writer() *LogWriter { return w.w }
+
+// Put encodes and inserts a new key/value pair
+func (w *TypedWriter[K, V]) Put(key K, val V) error {
+	return w.PutWithTTL(key, val, 0)
+}
+
+// PutWithTTL encodes and inserts a new key/value pair, which expires after ttl
+func (w *TypedWriter[K, V]) PutWithTTL(key K, val V, ttl time.Duration) error {
+	kb, err := w.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	vb, err := w.vals.Encode(val)
+	if err != nil {
+		return err
+	}
+	return w.w.PutWithTTL(kb, vb, ttl)
+}
+
+// TypedDB wraps a DB and encodes keys and decodes values using codecs
+type TypedDB[K, V any] struct {
+	db   *DB
+	keys Codec[K]
+	vals Codec[V]
+}
+
+// NewTypedDB wraps db
+func NewTypedDB[K, V any](db *DB, keys Codec[K], vals Codec[V]) *TypedDB[K, V] {
+	return &TypedDB[K, V]{db: db, keys: keys, vals: vals}
+}
+
+// DB returns the underlying database
+func (db *TypedDB[K, V]) DB() *DB { return db.db }
+
+// Get retrieves a key and returns a typed value iterator
+func (db *TypedDB[K, V]) Get(key K) (*TypedIterator[V], error) {
+	kb, err := db.keys.Encode(key)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := db.db.Get(kb)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedIterator[V]{Iterator: iter, vals: db.vals}, nil
+}
+
+// First returns the first value of a key, reports false if the key does not exist
+func (db *TypedDB[K, V]) First(key K) (V, bool, error) {
+	var zero V
+
+	iter, err := db.Get(key)
+	if err != nil {
+		return zero, false, err
+	}
+	if !iter.Next() {
+		return zero, false, iter.Error()
+	}
+	val, err := iter.Value()
+	if err != nil {
+		return zero, false, err
+	}
+	return val, true, nil
+}
+
+// TypedIterator iterates over decoded values
+type TypedIterator[V any] struct {
+	*Iterator
+	vals Codec[V]
+}
+
+// Value returns the decoded value
+func (i *TypedIterator[V]) Value() (V, error) {
+	b, err := i.Iterator.Value()
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return i.vals.Decode(b)
+}
+
+// All returns all decoded values
+func (i *TypedIterator[V]) All() ([]V, error) {
+	var vals []V
+	for i.Next() {
+		val, err := i.Value()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, i.Error()
+}
@@ -0,0 +1,118 @@
+//go:build go1.18
+// +build go1.18
+
+package ccdb
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IntCodec", func() {
+
+	It("should encode fixed-width", func() {
+		Expect(IntCodec[uint16]{}.Encode(0x1234)).To(Equal([]byte{0x12, 0x34}))
+		Expect(IntCodec[int32]{}.Encode(-1)).To(Equal([]byte{0x7f, 0xff, 0xff, 0xff}))
+		Expect(IntCodec[int64]{}.Encode(1)).To(Equal([]byte{0x80, 0, 0, 0, 0, 0, 0, 1}))
+
+		_, err := IntCodec[int32]{}.Decode([]byte{1, 2})
+		Expect(err).To(Equal(errCodecSize))
+	})
+
+	It("should preserve order", func() {
+		nums := []int64{math.MinInt64, -1000, -1, 0, 1, 255, 256, math.MaxInt64}
+		encoded := make([][]byte, 0, len(nums))
+		for _, n := range nums {
+			b, err := IntCodec[int64]{}.Encode(n)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(IntCodec[int64]{}.Decode(b)).To(Equal(n))
+			encoded = append(encoded, b)
+		}
+		Expect(sort.SliceIsSorted(encoded, func(i, j int) bool {
+			return bytes.Compare(encoded[i], encoded[j]) < 0
+		})).To(BeTrue())
+	})
+
+})
+
+var _ = Describe("TypedDB", func() {
+	var dir string
+
+	type record struct {
+		Name  string
+		Score int
+	}
+
+	BeforeEach(func() {
+		dir = mkTemp()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should write and read typed values", func() {
+		lname, iname := filepath.Join(dir, "test.ccl"), filepath.Join(dir, "test.cci")
+
+		writer, err := CreateLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer writer.Close()
+
+		tw := NewTypedWriter[uint32, record](writer, IntCodec[uint32]{}, JSONCodec[record]{})
+		Expect(tw.Put(7, record{Name: "foo", Score: 1})).To(Succeed())
+		Expect(tw.Put(8, record{Name: "bar", Score: 2})).To(Succeed())
+		Expect(tw.Put(7, record{Name: "baz", Score: 3})).To(Succeed())
+		Expect(tw.Writer().WriteIndex(iname)).To(Succeed())
+
+		db, err := Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		tdb := NewTypedDB[uint32, record](db, IntCodec[uint32]{}, JSONCodec[record]{})
+		iter, err := tdb.Get(7)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(iter.All()).To(Equal([]record{{Name: "foo", Score: 1}, {Name: "baz", Score: 3}}))
+
+		val, ok, err := tdb.First(8)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(val).To(Equal(record{Name: "bar", Score: 2}))
+
+		_, ok, err = tdb.First(9)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should support gob, strings and bytes", func() {
+		lname, iname := filepath.Join(dir, "test.ccl"), filepath.Join(dir, "test.cci")
+
+		writer, err := CreateLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer writer.Close()
+
+		Expect(NewTypedWriter[string, record](writer, StringCodec{}, GobCodec[record]{}).Put("foo", record{Name: "x"})).To(Succeed())
+		Expect(NewTypedWriter[[]byte, []byte](writer, BytesCodec{}, BytesCodec{}).Put([]byte("bar"), []byte("y"))).To(Succeed())
+		Expect(writer.WriteIndex(iname)).To(Succeed())
+
+		db, err := Open(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		val, ok, err := NewTypedDB[string, record](db, StringCodec{}, GobCodec[record]{}).First("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(val).To(Equal(record{Name: "x"}))
+
+		raw, ok, err := NewTypedDB[string, string](db, StringCodec{}, StringCodec{}).First("bar")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(raw).To(Equal("y"))
+	})
+
+})
@@ -0,0 +1,193 @@
+package ccdb
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const maxVerifyProblems = 100
+
+// VerifyReport contains the results of a verification
+type VerifyReport struct {
+	IndexInfo FileInfo `json:"index_info"`
+	LogInfo   FileInfo `json:"log_info"`
+
+	Entries int `json:"entries"` // number of log entries covered by the index
+	Slots   int `json:"slots"`   // number of used index slots
+
+	HeaderMismatch     bool `json:"header_mismatch"`     // index and log headers are incompatible
+	Stale              bool `json:"stale"`               // index does not cover the whole log
+	InvalidSlots       int  `json:"invalid_slots"`       // slots not pointing at an entry boundary
+	ChecksumMismatches int  `json:"checksum_mismatches"` // slots with checksums not matching the key
+	BucketMismatches   int  `json:"bucket_mismatches"`   // slots stored in the wrong bucket
+	Unreachable        int  `json:"unreachable"`         // slots which cannot be reached by probing
+	Unterminated       int  `json:"unterminated"`        // buckets without a terminating empty slot
+	Unindexed          int  `json:"unindexed"`           // unexpired entries missing from the index
+	Duplicates         int  `json:"duplicates"`          // entries indexed more than once
+
+	// Problems contains descriptions of the (first 100) problems found.
+	Problems []string `json:"problems"`
+}
+
+// OK returns true if no problems were found
+func (r *VerifyReport) OK() bool {
+	return !r.HeaderMismatch && !r.Stale &&
+		r.InvalidSlots == 0 && r.ChecksumMismatches == 0 && r.BucketMismatches == 0 &&
+		r.Unreachable == 0 && r.Unterminated == 0 && r.Unindexed == 0 && r.Duplicates == 0 &&
+		len(r.Problems) == 0
+}
+
+func (r *VerifyReport) problemf(format string, args ...interface{}) {
+	if len(r.Problems) < maxVerifyProblems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// Verify checks the consistency of an index and a log file.
+// Errors are only returned if the files cannot be read,
+// all inconsistencies are recorded in the report.
+func Verify(indexFileName, logFileName string) (*VerifyReport, error) {
+	index, err := OpenIndex(indexFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	log, err := OpenLog(logFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+
+	return verify(index, log)
+}
+
+func verify(index *IndexReader, log *LogReader) (*VerifyReport, error) {
+	report := &VerifyReport{IndexInfo: index.Info(), LogInfo: log.Info()}
+	now := time.Now()
+
+	// check headers
+	if index.header.id != log.header.id {
+		report.HeaderMismatch = true
+		report.problemf("file IDs differ: %08x != %08x", index.header.id, log.header.id)
+		return report, nil
+	}
+	if index.header.pos > log.header.pos {
+		report.HeaderMismatch = true
+		report.problemf("index covers %d bytes, beyond the log size of %d", index.header.pos, log.header.pos)
+		return report, nil
+	}
+	if index.header.pos < log.header.pos {
+		report.Stale = true
+		report.problemf("index covers %d of %d bytes", index.header.pos, log.header.pos)
+	}
+
+	// collect entries covered by the index
+	var positions []int64
+	expired := make(map[int64]bool)
+	indexed := make(map[int64]int)
+	checksums := make(map[int64]csum32)
+	iter := newLogIterator(log.file, log.header, index.header.pos)
+	iter.skipVal = true
+	for iter.Next() {
+		ent := iter.Entry()
+		checksums[ent.Pos] = ent.checksum()
+		indexed[ent.Pos] = 0
+		positions = append(positions, ent.Pos)
+		if ent.Expired(now) {
+			expired[ent.Pos] = true
+		}
+		report.Entries++
+	}
+	if err := iter.Error(); err != nil {
+		report.problemf("log is corrupt after %d entries: %s", report.Entries, err)
+	}
+
+	// check buckets and slots
+	stat, err := index.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tbuf := make([]byte, 12)
+	for bucket := 0; bucket < numBuckets; bucket++ {
+		offset, nslots, err := index.seekBucket(bucket, tbuf)
+		if err == io.EOF {
+			report.problemf("bucket %d: index is truncated", bucket)
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if nslots == 0 {
+			continue
+		}
+		if offset < index.header.dataOffset()+numBuckets*12 || offset+int64(nslots)*12 > stat.Size() {
+			report.problemf("bucket %d: slots at %d..%d are out of bounds", bucket, offset, offset+int64(nslots)*12)
+			continue
+		}
+
+		slots, err := index.readSlots(offset, nslots)
+		if err != nil {
+			return nil, err
+		}
+		verifyBucket(report, bucket, slots, checksums, indexed)
+	}
+
+	// check that every entry is indexed exactly once
+	for _, pos := range positions {
+		if n := indexed[pos]; n == 0 && expired[pos] {
+			continue
+		} else if n == 0 {
+			report.Unindexed++
+			report.problemf("entry at %d is not indexed", pos)
+		} else if n > 1 {
+			report.Duplicates++
+			report.problemf("entry at %d is indexed %d times", pos, n)
+		}
+	}
+	return report, nil
+}
+
+func verifyBucket(report *VerifyReport, bucket int, slots []slot, checksums map[int64]csum32, indexed map[int64]int) {
+	nslots := len(slots)
+	empty := 0
+
+	for n, s := range slots {
+		if s.lpos == 0 {
+			empty++
+			continue
+		}
+		report.Slots++
+
+		if cksum, ok := checksums[s.lpos]; !ok {
+			report.InvalidSlots++
+			report.problemf("bucket %d, slot %d: offset %d is not an entry boundary", bucket, n, s.lpos)
+			continue
+		} else if cksum != s.cksum {
+			report.ChecksumMismatches++
+			report.problemf("bucket %d, slot %d: checksum %08x does not match entry at %d", bucket, n, uint32(s.cksum), s.lpos)
+		}
+		indexed[s.lpos]++
+
+		if s.cksum.Bucket() != bucket {
+			report.BucketMismatches++
+			report.problemf("bucket %d, slot %d: checksum %08x belongs to bucket %d", bucket, n, uint32(s.cksum), s.cksum.Bucket())
+			continue
+		}
+
+		// probing starts at the home slot and stops at the first empty slot
+		for i := s.cksum.Slot() % nslots; i != n; i = (i + 1) % nslots {
+			if slots[i].lpos == 0 {
+				report.Unreachable++
+				report.problemf("bucket %d, slot %d: entry at %d is unreachable", bucket, n, s.lpos)
+				break
+			}
+		}
+	}
+
+	if empty == 0 {
+		report.Unterminated++
+		report.problemf("bucket %d: probe chains do not terminate", bucket)
+	}
+}
@@ -0,0 +1,119 @@
+package ccdb
+
+import (
+	"encoding/binary"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+	var dir, lname, iname string
+
+	var patchIndex = func(offset int64, data []byte) {
+		file, err := os.OpenFile(iname, os.O_WRONLY, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		_, err = file.WriteAt(data, offset)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// firstSlot returns the offset of the first used slot in the bucket of a key
+	var firstSlot = func(key string) int64 {
+		bucket := checksum([]byte(key)).Bucket()
+		index, err := OpenIndex(iname)
+		Expect(err).NotTo(HaveOccurred())
+		defer index.Close()
+
+		tbuf := make([]byte, 12)
+		offset, nslots, err := index.seekBucket(bucket, tbuf)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < nslots; i++ {
+			_, err := index.file.ReadAt(tbuf, offset+int64(i*12))
+			Expect(err).NotTo(HaveOccurred())
+			if binary.LittleEndian.Uint64(tbuf[4:]) != 0 {
+				return offset + int64(i*12)
+			}
+		}
+		Fail("no used slots")
+		return 0
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir = mkTemp()
+		lname, iname, err = writeTestLogAndIndex(dir, 500)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should pass valid databases", func() {
+		report, err := Verify(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.OK()).To(BeTrue())
+		Expect(report.Entries).To(Equal(1390))
+		Expect(report.Slots).To(Equal(1390))
+		Expect(report.Problems).To(BeEmpty())
+	})
+
+	It("should detect mismatching headers", func() {
+		other, _, err := writeTestWithCollisions(dir, 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		report, err := Verify(iname, other)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.OK()).To(BeFalse())
+		Expect(report.HeaderMismatch).To(BeTrue())
+	})
+
+	It("should detect stale indexes", func() {
+		writer, err := AppendLog(lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Put([]byte("key"), []byte("value"))).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		report, err := Verify(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Stale).To(BeTrue())
+		Expect(report.Entries).To(Equal(1390))
+		Expect(report.Problems).To(Equal([]string{"index covers 29318 of 29328 bytes"}))
+	})
+
+	It("should detect invalid offsets", func() {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, 129)
+		patchIndex(firstSlot("key.0000")+4, buf)
+
+		report, err := Verify(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.InvalidSlots).To(Equal(1))
+		Expect(report.Unindexed).To(Equal(1))
+		Expect(report.Problems).To(HaveLen(2))
+	})
+
+	It("should detect checksum mismatches", func() {
+		bucket := checksum([]byte("key.0000")).Bucket()
+		patchIndex(firstSlot("key.0000"), []byte{byte(bucket), 0, 0, 0})
+
+		report, err := Verify(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.ChecksumMismatches).To(Equal(1))
+		Expect(report.BucketMismatches).To(Equal(0))
+		Expect(report.OK()).To(BeFalse())
+	})
+
+	It("should detect bucket mismatches", func() {
+		bucket := checksum([]byte("key.0000")).Bucket()
+		patchIndex(firstSlot("key.0000"), []byte{byte(bucket + 1), 0, 0, 0})
+
+		report, err := Verify(iname, lname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.ChecksumMismatches).To(Equal(1))
+		Expect(report.BucketMismatches).To(Equal(1))
+	})
+})